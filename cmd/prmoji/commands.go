@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/adamantal/prmoji/internal/backfill"
+	"github.com/adamantal/prmoji/internal/cleanup"
+	"github.com/adamantal/prmoji/internal/config"
+	"github.com/adamantal/prmoji/internal/slack"
+	"github.com/adamantal/prmoji/internal/store"
+)
+
+// commandTimeout bounds the one-off store commands.
+const commandTimeout = 30 * time.Second
+
+// runCleanup implements `prmoji cleanup [-days N] [-policy P] [-dry-run]`, running the same
+// cleanup as POST /cleanup/ once and printing the result as JSON.
+func runCleanup(a *app, args []string) error {
+	opts := a.handlers().CleanupOptions()
+	fs := flag.NewFlagSet("cleanup", flag.ContinueOnError)
+	fs.IntVar(&opts.RetentionDays, "days", opts.RetentionDays, "retention window in days (default: $RETENTION_DAYS)")
+	policy := fs.String("policy", string(opts.Policy), "inserted or inactivity (default: $RETENTION_POLICY)")
+	fs.BoolVar(&opts.DryRun, "dry-run", false, "only count the rows that would be deleted")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errors.New("usage: prmoji cleanup [-days N] [-policy inserted|inactivity] [-dry-run]")
+	}
+	if opts.RetentionDays < a.cfg.CleanupMinDays {
+		return fmt.Errorf("days must be at least %d", a.cfg.CleanupMinDays)
+	}
+	var err error
+	if opts.Policy, err = config.ParseRetentionPolicy(*policy); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	res, err := cleanup.Run(ctx, a.store, opts, time.Now())
+	if err != nil {
+		return fmt.Errorf("cleanup: %w", err)
+	}
+	return printJSON(res)
+}
+
+// runMigrate implements `prmoji migrate`. Opening the store creates missing tables and
+// columns, so this only has to open it; serve does the same on startup.
+func runMigrate(a *app, args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errors.New("usage: prmoji migrate")
+	}
+	fmt.Println("database schema is up to date:", a.cfg.DBPath)
+	return nil
+}
+
+type trackedPR struct {
+	store.TrackedPR
+	Messages []store.Message `json:"messages"`
+}
+
+// runList implements `prmoji list [-channel C] [-limit N] [-offset N]`, printing the tracked
+// PRs and their messages as JSON.
+func runList(a *app, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	channel := fs.String("channel", "", "only PRs tracked in this channel ID")
+	limit := fs.Int("limit", 50, "maximum number of PRs to print")
+	offset := fs.Int("offset", 0, "number of PRs to skip")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 || *limit <= 0 || *offset < 0 {
+		return errors.New("usage: prmoji list [-channel C] [-limit N] [-offset N]")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	var prs []store.TrackedPR
+	var err error
+	if *channel != "" {
+		prs, err = a.store.ListTrackedPRsInChannel(ctx, *channel, *limit, *offset)
+	} else {
+		prs, err = a.store.ListTrackedPRs(ctx, *limit, *offset)
+	}
+	if err != nil {
+		return fmt.Errorf("list tracked prs: %w", err)
+	}
+
+	items := make([]trackedPR, 0, len(prs))
+	for _, pr := range prs {
+		msgs, err := a.store.ListMessagesByPRURL(ctx, pr.PRURL)
+		if err != nil {
+			return fmt.Errorf("list messages: %w", err)
+		}
+		items = append(items, trackedPR{TrackedPR: pr, Messages: msgs})
+	}
+	return printJSON(items)
+}
+
+// runTrack implements `prmoji track [-team T] <pr url> <channel> <ts>`, attaching a PR to a
+// Slack message the bot missed.
+func runTrack(a *app, args []string) error {
+	fs := flag.NewFlagSet("track", flag.ContinueOnError)
+	team := fs.String("team", "", "Slack team ID of a workspace installed through OAuth (default: the SLACK_TOKEN workspace)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 3 || !slack.IsPRURL(fs.Arg(0)) {
+		return errors.New("usage: prmoji track [-team T] <pr url> <channel> <ts>")
+	}
+	prURL, channel, ts := fs.Arg(0), fs.Arg(1), fs.Arg(2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	inserted, err := a.store.TrackPRMessage(ctx, prURL, *team, channel, ts)
+	if err != nil {
+		return fmt.Errorf("track message: %w", err)
+	}
+	if !inserted {
+		fmt.Println("already tracked")
+		return nil
+	}
+	a.log.Info("tracked message", "pr_url", prURL, "channel", channel, "ts", ts)
+	fmt.Println("tracked")
+	return nil
+}
+
+// runUntrack implements `prmoji untrack <pr url>` and `prmoji untrack -id N`, forgetting a PR
+// or a single tracked message.
+func runUntrack(a *app, args []string) error {
+	fs := flag.NewFlagSet("untrack", flag.ContinueOnError)
+	id := fs.Int64("id", 0, "ID of a single tracked message, as printed by list")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	byID := *id > 0 && fs.NArg() == 0
+	if !byID && (fs.NArg() != 1 || !slack.IsPRURL(fs.Arg(0))) {
+		return errors.New("usage: prmoji untrack <pr url> | -id N")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	if byID {
+		found, err := a.store.DeleteMessage(ctx, *id)
+		if err != nil {
+			return fmt.Errorf("untrack message: %w", err)
+		}
+		if !found {
+			return fmt.Errorf("message %d not found", *id)
+		}
+		a.log.Info("untracked message", "id", *id)
+	} else {
+		if err := a.store.DeleteByPRURL(ctx, fs.Arg(0)); err != nil {
+			return fmt.Errorf("untrack pr: %w", err)
+		}
+		a.log.Info("untracked pr", "pr_url", fs.Arg(0))
+	}
+	fmt.Println("untracked")
+	return nil
+}
+
+// runReplay implements `prmoji replay [-dry-run] <id>`, re-processing one archived
+// webhook delivery and printing the result as JSON.
+func runReplay(a *app, args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "log the Slack calls and store writes instead of making them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: prmoji replay [-dry-run] <delivery id>")
+	}
+	id, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid delivery id: %q", fs.Arg(0))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	res, err := a.handlers().Replay(ctx, id, *dryRun)
+	if err != nil {
+		return fmt.Errorf("replay delivery %d: %w", id, err)
+	}
+	return printJSON(res)
+}
+
+// runConfig implements `prmoji config validate [-file path]`, reporting every invalid setting
+// of the environment and config file.
+func runConfig(args []string) error {
+	if len(args) == 0 || args[0] != "validate" {
+		return errors.New("usage: prmoji config validate [-file path]")
+	}
+	fs := flag.NewFlagSet("config validate", flag.ContinueOnError)
+	file := fs.String("file", os.Getenv("CONFIG_FILE"), "config file to validate (default: $CONFIG_FILE)")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if _, err := config.LoadFile(*file); err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	fmt.Println("configuration is valid")
+	return nil
+}
+
+// runBackfill implements `prmoji backfill [-since 24h|RFC3339] [-channels C1,C2] [-team T1] [-dry-run]`,
+// tracking PR links posted while prmoji wasn't listening.
+func runBackfill(a *app, args []string) error {
+	fs := flag.NewFlagSet("backfill", flag.ContinueOnError)
+	since := fs.String("since", "24h", "how far back to look: a duration like 72h or an RFC 3339 time")
+	channels := fs.String("channels", "", "comma-separated channel IDs (default: every channel the bot is in)")
+	team := fs.String("team", "", "Slack team ID of a workspace installed through OAuth (default: the SLACK_TOKEN workspace)")
+	dryRun := fs.Bool("dry-run", false, "only log the PR links that would be tracked")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	sc := a.slack
+	opts := backfill.Options{TeamID: *team, Rules: a.cfg.ChannelRules, DryRun: *dryRun}
+	if *team != "" {
+		inst, err := a.store.GetSlackInstallation(ctx, *team)
+		if err != nil {
+			return fmt.Errorf("slack installation %s: %w", *team, err)
+		}
+		sc = sc.WithToken(inst.BotToken)
+	}
+	if d, err := time.ParseDuration(*since); err == nil {
+		opts.Since = time.Now().Add(-d)
+	} else if opts.Since, err = time.Parse(time.RFC3339, *since); err != nil {
+		return fmt.Errorf("invalid -since: %q", *since)
+	}
+	for _, ch := range strings.Split(*channels, ",") {
+		if ch = strings.TrimSpace(ch); ch != "" {
+			opts.Channels = append(opts.Channels, ch)
+		}
+	}
+
+	res, err := backfill.Run(ctx, a.store, sc, opts)
+	if err != nil {
+		return fmt.Errorf("backfill: %w", err)
+	}
+	return printJSON(res)
+}
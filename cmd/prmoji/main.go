@@ -2,88 +2,152 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
-	"net/http"
 	"os"
-	"os/signal"
-	"syscall"
 	"time"
 
-	"github.com/adamantal/prmoji/internal/cleanup"
 	"github.com/adamantal/prmoji/internal/config"
 	httpHandlers "github.com/adamantal/prmoji/internal/http"
 	"github.com/adamantal/prmoji/internal/log"
 	"github.com/adamantal/prmoji/internal/slack"
 	"github.com/adamantal/prmoji/internal/store"
+	"github.com/adamantal/prmoji/internal/tracing"
 )
 
+// version is set at build time with -ldflags "-X main.version=...".
+var version = "dev"
+
+const usage = `usage: prmoji <command> [flags]
+
+commands:
+  serve       run the server (the default)
+  cleanup     delete stale mappings once, like POST /cleanup/
+  migrate     create or upgrade the database schema
+  list        list the tracked PRs
+  track       track a Slack message for a PR
+  untrack     stop tracking a PR or a single message
+  backfill    track PR links posted while prmoji wasn't listening
+  replay      process an archived webhook delivery again
+  config      validate the configuration
+  version     print the version
+
+Run 'prmoji <command> -h' for the flags of a command.`
+
+// command runs a subcommand that needs the configuration and the store.
+type command func(a *app, args []string) error
+
+var commands = map[string]command{
+	"serve":    runServe,
+	"cleanup":  runCleanup,
+	"migrate":  runMigrate,
+	"list":     runList,
+	"track":    runTrack,
+	"untrack":  runUntrack,
+	"backfill": runBackfill,
+	"replay":   runReplay,
+}
+
 func main() {
-	cfg, err := config.Load()
-	if err != nil {
+	name, args := "serve", os.Args[1:]
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+	if err := run(name, args); err != nil {
 		_, _ = fmt.Fprintln(os.Stderr, err.Error())
 		os.Exit(1)
 	}
+}
+
+func run(name string, args []string) error {
+	switch name {
+	case "version", "-version", "--version":
+		fmt.Println("prmoji", version)
+		return nil
+	case "help", "-h", "-help", "--help":
+		fmt.Println(usage)
+		return nil
+	case "config":
+		// Validating must not stop at the first invalid setting, so it runs before Load.
+		return runConfig(args)
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		return fmt.Errorf("unknown command: %q\n\n%s", name, usage)
+	}
+
+	a, err := newApp()
+	if err != nil {
+		return err
+	}
+	defer a.close()
+	return cmd(a, args)
+}
 
-	logger := log.New(cfg.LogLevel)
+// app is what the commands share: the configuration, the logger, the store and the Slack client.
+type app struct {
+	cfg   config.Config
+	log   *slog.Logger
+	store *store.SQLiteStore
+	slack *slack.Client
+
+	shutdownTracing func(context.Context) error
+}
+
+func newApp() (*app, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	logger := log.New(cfg.LogLevel, cfg.LogFormat)
 	slog.SetDefault(logger)
 
-	st, err := store.NewSQLiteStore(cfg.DBPath)
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.TracingExporter, cfg.TracingEndpoint)
 	if err != nil {
-		logger.Error("failed to init store", "err", err)
-		os.Exit(1)
+		return nil, fmt.Errorf("init tracing: %w", err)
 	}
-	defer func() {
-		_ = st.Close()
-	}()
-
-	slackClient := slack.NewClient(cfg.SlackToken)
-
-	mux := http.NewServeMux()
-	h := &httpHandlers.Handlers{Cfg: cfg, Store: st, Slack: slackClient, Log: logger}
-	h.Register(mux)
-
-	srv := &http.Server{
-		Addr:              fmt.Sprintf(":%d", cfg.Port),
-		Handler:           mux,
-		ReadHeaderTimeout: 5 * time.Second,
-		ReadTimeout:       10 * time.Second,
-		WriteTimeout:      10 * time.Second,
-		IdleTimeout:       60 * time.Second,
+
+	sc, err := slack.NewClientWithOptions(cfg.SlackToken, slack.Options{
+		BaseURL: cfg.SlackAPIURL,
+		Proxy:   cfg.SlackProxy,
+		CAFile:  cfg.SlackCAFile,
+		Timeout: cfg.SlackTimeout,
+	})
+	if err != nil {
+		_ = shutdownTracing(context.Background())
+		return nil, fmt.Errorf("init slack client: %w", err)
 	}
 
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
-	defer stop()
-
-	go func() {
-		ticker := time.NewTicker(24 * time.Hour)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-ticker.C:
-				runCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-				_, err := cleanup.Run(runCtx, st, cfg.RetentionDays, time.Now())
-				cancel()
-				if err != nil {
-					logger.Error("background cleanup failed", "err", err)
-				}
-			case <-ctx.Done():
-				return
-			}
-		}
-	}()
-
-	go func() {
-		logger.Info("listening", "addr", srv.Addr)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			logger.Error("http server failed", "err", err)
-			os.Exit(1)
-		}
-	}()
-
-	<-ctx.Done()
-	logger.Info("shutting down")
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	st, err := store.NewSQLiteStore(cfg.DBPath)
+	if err != nil {
+		_ = shutdownTracing(context.Background())
+		return nil, fmt.Errorf("init store: %w", err)
+	}
+
+	return &app{
+		cfg:             cfg,
+		log:             logger,
+		store:           st,
+		slack:           sc,
+		shutdownTracing: shutdownTracing,
+	}, nil
+}
+
+func (a *app) close() {
+	_ = a.store.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	_ = srv.Shutdown(shutdownCtx)
+	_ = a.shutdownTracing(ctx)
+}
+
+func (a *app) handlers() *httpHandlers.Handlers {
+	return &httpHandlers.Handlers{Cfg: a.cfg, Store: a.store, Slack: a.slack, Log: a.log}
+}
+
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
 }
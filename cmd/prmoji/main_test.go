@@ -0,0 +1,35 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("SLACK_TOKEN", "xoxb-test")
+	t.Setenv("DB_PATH", filepath.Join(t.TempDir(), "prmoji.db"))
+
+	cases := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"unknown command", []string{"frobnicate"}, `unknown command: "frobnicate"`},
+		{"untrack without arguments", []string{"untrack"}, "usage: prmoji untrack"},
+		{"cleanup below the minimum", []string{"cleanup", "-days", "3"}, "days must be at least 7"},
+		{"cleanup dry run", []string{"cleanup", "-days", "7", "-dry-run"}, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := run(tc.args[0], tc.args[1:])
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)):
+				t.Fatalf("expected an error containing %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/adamantal/prmoji/internal/backfill"
+	"github.com/adamantal/prmoji/internal/cleanup"
+	"github.com/adamantal/prmoji/internal/config"
+	"github.com/adamantal/prmoji/internal/github"
+	httpHandlers "github.com/adamantal/prmoji/internal/http"
+	"github.com/adamantal/prmoji/internal/metrics"
+	"github.com/adamantal/prmoji/internal/slack"
+	"github.com/adamantal/prmoji/internal/store"
+)
+
+// queueFlushInterval is how often queued reactions are checked; it bounds how late a
+// debounced reaction is added.
+const queueFlushInterval = time.Second
+
+// runServe implements `prmoji serve`, running the HTTP server and the background loops
+// until SIGINT or SIGTERM.
+func runServe(a *app, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cfg, st, logger, slackClient := a.cfg, a.store, a.log, a.slack
+
+	metrics.RegisterTrackedPRs(func() (int64, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return st.CountTrackedPRs(ctx)
+	})
+	metrics.RegisterQueuedReactions(func() (int64, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return st.CountQueuedReactions(ctx)
+	})
+
+	if cfg.DryRun {
+		logger.Warn("dry run: events are processed but no reactions, replies or store writes are made")
+	}
+	var tokenInfo slack.AuthInfo
+	if cfg.SlackToken != "" {
+		var err error
+		if tokenInfo, err = checkSlackToken(slackClient); err != nil {
+			return fmt.Errorf("slack token check failed: %w", err)
+		}
+		if tokenInfo.TeamID != "" {
+			assignLegacyTeam(st, tokenInfo.TeamID)
+		}
+	}
+
+	mux := http.NewServeMux()
+	h := a.handlers()
+	switch {
+	case cfg.GitHubAppID != 0:
+		var err error
+		h.GitHub, err = github.NewAppClient(cfg.GitHubAppID, []byte(cfg.GitHubAppPrivateKey), cfg.GitHubAPIURL)
+		if err != nil {
+			return fmt.Errorf("invalid github app credentials: %w", err)
+		}
+	case cfg.GitHubToken != "":
+		h.GitHub = github.NewClient(cfg.GitHubToken, cfg.GitHubAPIURL)
+	}
+	h.Register(mux)
+
+	srv := &http.Server{
+		Addr:              fmt.Sprintf(":%d", cfg.Port),
+		Handler:           httpHandlers.WithRequestID(mux),
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       10 * time.Second,
+		WriteTimeout:      10 * time.Second,
+		IdleTimeout:       60 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		ticker := time.NewTicker(24 * time.Hour)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				runCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+				_, err := cleanup.Run(runCtx, st, h.CleanupOptions(), time.Now())
+				cancel()
+				if err != nil {
+					logger.Error("background cleanup failed", "err", err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	if cfg.SlackMode == config.SlackModeSocket {
+		socket := slack.NewSocketMode(slackClient.WithToken(cfg.SlackAppToken))
+		go func() {
+			if err := socket.Run(ctx, h.HandleSocketEvent); err != nil && !errors.Is(err, context.Canceled) {
+				logger.Error("socket mode stopped", "err", err)
+			}
+		}()
+	}
+
+	if cfg.BackfillOnStart > 0 {
+		go func() {
+			since := time.Now().Add(-cfg.BackfillOnStart)
+			if cfg.SlackToken != "" {
+				opts := backfill.Options{Since: since, TeamID: tokenInfo.TeamID, Rules: h.ChannelRules()}
+				if _, err := backfill.Run(ctx, st, slackClient, opts); err != nil && !errors.Is(err, context.Canceled) {
+					logger.Error("startup backfill failed", "err", err)
+				}
+			}
+			installs, err := st.ListSlackInstallations(ctx)
+			if err != nil {
+				logger.Error("list slack installations failed", "err", err)
+				return
+			}
+			for _, inst := range installs {
+				opts := backfill.Options{Since: since, TeamID: inst.TeamID, Rules: h.ChannelRules()}
+				if _, err := backfill.Run(ctx, st, slackClient.WithToken(inst.BotToken), opts); err != nil && !errors.Is(err, context.Canceled) {
+					logger.Error("startup backfill failed", "err", err, "team_id", inst.TeamID)
+				}
+			}
+		}()
+	}
+
+	if cfg.ReactionRetryInterval > 0 {
+		go func() {
+			ticker := time.NewTicker(cfg.ReactionRetryInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					runCtx, cancel := context.WithTimeout(context.Background(), cfg.ReactionRetryInterval)
+					_, err := h.RetryFailedReactions(runCtx)
+					cancel()
+					if err != nil {
+						logger.Error("reaction retry failed", "err", err)
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	if cfg.ConfigFile != "" {
+		err := config.Watch(cfg.ConfigFile, h.Reload, func(err error) {
+			logger.Error("ignoring invalid config file change", "file", cfg.ConfigFile, "err", err)
+		})
+		if err != nil {
+			return fmt.Errorf("watch config file: %w", err)
+		}
+	}
+
+	// A reloaded config file may turn queueing on, so keep flushing whenever there is one.
+	if h.QueuesReactions() || cfg.ConfigFile != "" {
+		go func() {
+			ticker := time.NewTicker(queueFlushInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					runCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+					_, err := h.FlushQueuedReactions(runCtx)
+					cancel()
+					if err != nil {
+						logger.Error("flush queued reactions failed", "err", err)
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		logger.Info("listening", "addr", srv.Addr)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("http server failed: %w", err)
+	case <-ctx.Done():
+	}
+	logger.Info("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return srv.Shutdown(shutdownCtx)
+}
+
+// checkSlackToken fails on tokens Slack rejects or that can't add reactions. Transport
+// errors are only logged so a Slack blip doesn't crash-loop the pod; the returned info is
+// empty then.
+func checkSlackToken(c *slack.Client) (slack.AuthInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	info, err := c.AuthTest(ctx)
+	var apiErr *slack.APIError
+	if errors.As(err, &apiErr) {
+		return slack.AuthInfo{}, fmt.Errorf("SLACK_TOKEN rejected by Slack (%s)", apiErr.Code)
+	}
+	if err != nil {
+		slog.Warn("could not verify SLACK_TOKEN", "err", err)
+		return slack.AuthInfo{}, nil
+	}
+	if !info.HasScope(slack.ScopeReactionsWrite) {
+		return slack.AuthInfo{}, fmt.Errorf("SLACK_TOKEN lacks the %s scope (has: %s)", slack.ScopeReactionsWrite, strings.Join(info.Scopes, ","))
+	}
+	slog.Info("slack token verified", "team", info.Team, "team_id", info.TeamID, "user_id", info.UserID)
+	return info, nil
+}
+
+// assignLegacyTeam moves messages tracked before workspaces were recorded to the workspace of
+// SLACK_TOKEN, which they came from, so that workspace-scoped lookups find them.
+func assignLegacyTeam(st *store.SQLiteStore, teamID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	n, err := st.AssignLegacyTeam(ctx, teamID)
+	if err != nil {
+		slog.Error("assign legacy messages to the slack token's workspace failed", "err", err, "team_id", teamID)
+		return
+	}
+	if n > 0 {
+		slog.Info("assigned legacy messages to the slack token's workspace", "team_id", teamID, "messages", n)
+	}
+}
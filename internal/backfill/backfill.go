@@ -0,0 +1,172 @@
+package backfill
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/adamantal/prmoji/internal/github"
+	"github.com/adamantal/prmoji/internal/metrics"
+	"github.com/adamantal/prmoji/internal/routing"
+	"github.com/adamantal/prmoji/internal/slack"
+	"github.com/adamantal/prmoji/internal/store"
+)
+
+type Options struct {
+	// Since is the oldest message time to look at.
+	Since time.Time
+	// Channels limits the backfill to these channel IDs; empty means every channel the bot is in.
+	Channels []string
+	// TeamID is the workspace sc belongs to, recorded on the tracked messages.
+	TeamID string
+	// Rules skip PR links that CHANNEL_RULES don't route to the channel they were posted in.
+	Rules  routing.Rules
+	DryRun bool
+}
+
+type ChannelResult struct {
+	Channel  string `json:"channel"`
+	Messages int    `json:"messages"`
+	PRURLs   int    `json:"pr_urls"`
+	Skipped  int    `json:"skipped,omitempty"`
+	Inserted int    `json:"inserted"`
+	Error    string `json:"error,omitempty"`
+}
+
+type Result struct {
+	Since      string          `json:"since"`
+	DryRun     bool            `json:"dry_run"`
+	Channels   []ChannelResult `json:"channels"`
+	Inserted   int             `json:"inserted"`
+	DurationMS int64           `json:"duration_ms"`
+}
+
+// Run walks the history of each channel, including thread replies, back to opts.Since and
+// tracks every PR URL that isn't tracked for its message yet. Running it twice is harmless.
+// A failing channel (e.g. one the bot was removed from) is reported and skipped.
+//
+// Threads are only walked from parents posted after opts.Since: conversations.history has no
+// way to find older threads with recent replies short of reading the whole channel, so links
+// replied to such threads are missed.
+func Run(ctx context.Context, st *store.SQLiteStore, sc *slack.Client, opts Options) (Result, error) {
+	start := time.Now()
+	res := Result{Since: opts.Since.UTC().Format(time.RFC3339), DryRun: opts.DryRun}
+
+	channels := opts.Channels
+	if len(channels) == 0 {
+		var err error
+		if channels, err = sc.BotChannels(ctx); err != nil {
+			return Result{}, err
+		}
+	}
+	slog.InfoContext(ctx, "running backfill", "team_id", opts.TeamID, "since", res.Since, "channels", len(channels), "dry_run", opts.DryRun)
+
+	b := backfiller{st: st, sc: sc, teamID: opts.TeamID, rules: opts.Rules, oldest: slack.TS(opts.Since), dryRun: opts.DryRun}
+	for _, ch := range channels {
+		cr := b.channel(ctx, ch)
+		if ctx.Err() != nil {
+			return Result{}, ctx.Err()
+		}
+		slog.DebugContext(ctx, "backfilled channel", "channel", ch, "messages", cr.Messages, "pr_urls", cr.PRURLs, "inserted", cr.Inserted, "err", cr.Error)
+		res.Inserted += cr.Inserted
+		res.Channels = append(res.Channels, cr)
+	}
+	res.DurationMS = time.Since(start).Milliseconds()
+	slog.InfoContext(ctx, "backfill finished", "inserted", res.Inserted, "duration_ms", res.DurationMS)
+	return res, nil
+}
+
+type backfiller struct {
+	st     *store.SQLiteStore
+	sc     *slack.Client
+	teamID string
+	rules  routing.Rules
+	oldest string
+	dryRun bool
+	// channelName is set per channel (on a copy) when rules match channels by name.
+	channelName string
+}
+
+func (b backfiller) channel(ctx context.Context, channel string) ChannelResult {
+	cr := ChannelResult{Channel: channel}
+	fail := func(err error) ChannelResult {
+		slog.WarnContext(ctx, "backfill channel failed", "channel", channel, "err", err)
+		cr.Error = err.Error()
+		return cr
+	}
+	if b.rules.UsesNames() {
+		name, err := b.sc.ChannelName(ctx, channel)
+		if err != nil {
+			return fail(err)
+		}
+		b.channelName = name
+	}
+
+	var cursor string
+	for {
+		msgs, next, err := b.sc.History(ctx, channel, b.oldest, cursor)
+		if err != nil {
+			return fail(err)
+		}
+		for _, m := range msgs {
+			if err := b.message(ctx, &cr, m); err != nil {
+				return fail(err)
+			}
+			// Older parents never show up here, so neither do their recent replies; see Run.
+			if m.ReplyCount > 0 {
+				if err := b.thread(ctx, &cr, m.TS); err != nil {
+					return fail(err)
+				}
+			}
+		}
+		if cursor = next; cursor == "" {
+			return cr
+		}
+	}
+}
+
+func (b backfiller) thread(ctx context.Context, cr *ChannelResult, threadTS string) error {
+	var cursor string
+	for {
+		msgs, next, err := b.sc.Replies(ctx, cr.Channel, threadTS, b.oldest, cursor)
+		if err != nil {
+			return err
+		}
+		for _, m := range msgs {
+			// The parent is repeated at the top of every page and was already handled.
+			if m.TS == threadTS {
+				continue
+			}
+			if err := b.message(ctx, cr, m); err != nil {
+				return err
+			}
+		}
+		if cursor = next; cursor == "" {
+			return nil
+		}
+	}
+}
+
+func (b backfiller) message(ctx context.Context, cr *ChannelResult, m slack.HistoryMessage) error {
+	cr.Messages++
+	for _, u := range slack.ExtractPRURLs(m.Text) {
+		cr.PRURLs++
+		if pr, ok := github.ParsePRURL(u); !ok || !b.rules.Allows(cr.Channel, b.channelName, pr.Owner+"/"+pr.Repo) {
+			cr.Skipped++
+			continue
+		}
+		if b.dryRun {
+			slog.InfoContext(ctx, "dry run: would track pr message", "pr_url", u, "channel", cr.Channel, "ts", m.TS)
+			continue
+		}
+		inserted, err := b.st.TrackPRMessage(ctx, u, b.teamID, cr.Channel, m.TS)
+		if err != nil {
+			return err
+		}
+		if inserted {
+			cr.Inserted++
+			metrics.PRURLsIngested.Inc()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package backfill
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/adamantal/prmoji/internal/routing"
+	"github.com/adamantal/prmoji/internal/slack"
+	"github.com/adamantal/prmoji/internal/store"
+)
+
+// newSlack returns a client of a Slack stand-in where the bot is in C1, holding a message
+// linking o/r#1 with a reply linking o/r#2 and a message linking x/y#3, and in C2, which it
+// can't read.
+func newSlack(t *testing.T) *slack.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/users.conversations":
+			_, _ = w.Write([]byte(`{"ok":true,"channels":[{"id":"C1"},{"id":"C2"}]}`))
+		case "/conversations.history":
+			if r.FormValue("channel") != "C1" {
+				_, _ = w.Write([]byte(`{"ok":false,"error":"not_in_channel"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"ok":true,"messages":[
+				{"type":"message","ts":"1.0","text":"<https://github.com/o/r/pull/1>","reply_count":1},
+				{"type":"message","ts":"2.0","text":"<https://github.com/x/y/pull/3>"}]}`))
+		case "/conversations.replies":
+			_, _ = w.Write([]byte(`{"ok":true,"messages":[
+				{"type":"message","ts":"1.0","text":"<https://github.com/o/r/pull/1>","reply_count":1},
+				{"type":"message","ts":"1.1","thread_ts":"1.0","text":"<https://github.com/o/r/pull/2>"}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	sc, err := slack.NewClientWithOptions("xoxb-test", slack.Options{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	return sc
+}
+
+func TestRun(t *testing.T) {
+	st, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "prmoji.db"))
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+	sc := newSlack(t)
+	ctx := context.Background()
+	rules, err := routing.Parse("C1=o/*")
+	if err != nil {
+		t.Fatalf("parse rules: %v", err)
+	}
+	opts := Options{Since: time.Now().Add(-time.Hour), TeamID: "T1", Rules: rules}
+
+	steps := []struct {
+		name     string
+		dryRun   bool
+		inserted int
+	}{
+		{"dry run", true, 0},
+		{"first run", false, 2},
+		{"repeated run", false, 0},
+	}
+	for _, step := range steps {
+		opts.DryRun = step.dryRun
+		res, err := Run(ctx, st, sc, opts)
+		if err != nil {
+			t.Fatalf("%s: run: %v", step.name, err)
+		}
+		if res.Inserted != step.inserted || len(res.Channels) != 2 {
+			t.Fatalf("%s: expected %d inserted got %+v", step.name, step.inserted, res)
+		}
+		c1, c2 := res.Channels[0], res.Channels[1]
+		if c1.Messages != 3 || c1.PRURLs != 3 || c1.Skipped != 1 || c1.Inserted != step.inserted || c1.Error != "" {
+			t.Fatalf("%s: unexpected C1 result %+v", step.name, c1)
+		}
+		if c2.Error == "" {
+			t.Fatalf("%s: expected C2 to fail, got %+v", step.name, c2)
+		}
+	}
+
+	for prURL, want := range map[string]int{"https://github.com/o/r/pull/1": 1, "https://github.com/o/r/pull/2": 1, "https://github.com/x/y/pull/3": 0} {
+		msgs, err := st.ListMessagesByPRURL(ctx, prURL)
+		if err != nil || len(msgs) != want {
+			t.Fatalf("%s: expected %d messages got %+v (%v)", prURL, want, msgs, err)
+		}
+		if want > 0 && msgs[0].TeamID != "T1" {
+			t.Fatalf("%s: expected the message in T1, got %+v", prURL, msgs[0])
+		}
+	}
+}
@@ -3,17 +3,180 @@ package cleanup
 import (
 	"context"
 	"log/slog"
+	"sort"
 	"time"
 
+	"github.com/adamantal/prmoji/internal/config"
+	"github.com/adamantal/prmoji/internal/metrics"
 	"github.com/adamantal/prmoji/internal/store"
 )
 
+// AllChannels is the ChannelResult.Channel of the default retention window, which covers
+// every channel without an override.
+const AllChannels = "*"
+
+type Options struct {
+	RetentionDays int
+	Policy        config.RetentionPolicy
+	// ChannelDays overrides RetentionDays for individual channel IDs.
+	ChannelDays map[string]int
+	// ArchiveDays is how long archived webhook payloads are kept; 0 purges all but today's.
+	ArchiveDays int
+	// DryRun only counts the rows that would be deleted.
+	DryRun bool
+}
+
+type TableResult struct {
+	Table   string `json:"table"`
+	Matched int64  `json:"matched"`
+	Deleted int64  `json:"deleted"`
+}
+
+type ChannelResult struct {
+	Channel       string `json:"channel"`
+	RetentionDays int    `json:"retention_days"`
+	Cutoff        string `json:"cutoff"`
+	Matched       int64  `json:"matched"`
+	Deleted       int64  `json:"deleted"`
+}
+
+type Result struct {
+	Cutoff        string          `json:"cutoff"`
+	RetentionDays int             `json:"retention_days"`
+	Policy        string          `json:"policy"`
+	DryRun        bool            `json:"dry_run"`
+	Tables        []TableResult   `json:"tables"`
+	Channels      []ChannelResult `json:"channels"`
+	DurationMS    int64           `json:"duration_ms"`
+}
+
+// Deleted returns the total number of rows deleted across all tables.
+func (r Result) Deleted() int64 {
+	var n int64
+	for _, t := range r.Tables {
+		n += t.Deleted
+	}
+	return n
+}
+
 func CutoffDateUTC(now time.Time, days int) time.Time {
 	today := now.UTC().Truncate(24 * time.Hour)
 	return today.AddDate(0, 0, -days)
 }
 
-func Run(ctx context.Context, st *store.SQLiteStore, retentionDays int, now time.Time) (int64, error) {
-	slog.Info("running cleanup", "retention_days", retentionDays)
-	return st.DeleteOlderThanDate(ctx, CutoffDateUTC(now, retentionDays))
+func Run(ctx context.Context, st *store.SQLiteStore, opts Options, now time.Time) (Result, error) {
+	start := time.Now()
+	if opts.Policy == "" {
+		opts.Policy = config.RetentionInactivity
+	}
+	cutoff := CutoffDateUTC(now, opts.RetentionDays)
+	res := Result{
+		Cutoff:        cutoff.Format("2006-01-02"),
+		RetentionDays: opts.RetentionDays,
+		Policy:        string(opts.Policy),
+		DryRun:        opts.DryRun,
+	}
+	slog.InfoContext(ctx, "running cleanup", "retention_days", opts.RetentionDays, "policy", opts.Policy, "cutoff", res.Cutoff, "dry_run", opts.DryRun)
+
+	count, del := st.CountInactiveBeforeDate, st.DeleteInactiveBeforeDate
+	if opts.Policy == config.RetentionInserted {
+		count, del = st.CountOlderThanDate, st.DeleteOlderThanDate
+	}
+
+	type window struct {
+		scope store.ChannelScope
+		name  string
+		days  int
+	}
+	overridden := make([]string, 0, len(opts.ChannelDays))
+	for ch := range opts.ChannelDays {
+		overridden = append(overridden, ch)
+	}
+	sort.Strings(overridden)
+	windows := make([]window, 0, len(overridden)+1)
+	for _, ch := range overridden {
+		windows = append(windows, window{scope: store.ChannelScope{Channel: ch}, name: ch, days: opts.ChannelDays[ch]})
+	}
+	windows = append(windows, window{scope: store.ChannelScope{Exclude: overridden}, name: AllChannels, days: opts.RetentionDays})
+
+	tr := TableResult{Table: store.TablePRMessages}
+	for _, w := range windows {
+		wCutoff := CutoffDateUTC(now, w.days)
+		cr := ChannelResult{Channel: w.name, RetentionDays: w.days, Cutoff: wCutoff.Format("2006-01-02")}
+		matched, err := count(ctx, wCutoff, w.scope)
+		if err != nil {
+			return Result{}, err
+		}
+		cr.Matched = matched
+		if !opts.DryRun && matched > 0 {
+			cr.Deleted, err = del(ctx, wCutoff, w.scope)
+			if err != nil {
+				return Result{}, err
+			}
+		}
+		slog.DebugContext(ctx, "cleaned up channel", "channel", cr.Channel, "retention_days", cr.RetentionDays, "matched", cr.Matched, "deleted", cr.Deleted)
+		tr.Matched += cr.Matched
+		tr.Deleted += cr.Deleted
+		res.Channels = append(res.Channels, cr)
+	}
+	res.Tables = append(res.Tables, tr)
+
+	// Side tables are keyed by PR URL and only cleared once a PR has no messages left.
+	// In dry-run mode this only reflects rows that are already orphaned.
+	orphans := []struct {
+		table string
+		count func(context.Context) (int64, error)
+		del   func(context.Context) (int64, error)
+	}{
+		{store.TablePRActivity, st.CountOrphanedActivity, st.DeleteOrphanedActivity},
+		{store.TablePRReactions, st.CountOrphanedReactions, st.DeleteOrphanedReactions},
+	}
+	for _, o := range orphans {
+		matched, err := o.count(ctx)
+		if err != nil {
+			return Result{}, err
+		}
+		tr := TableResult{Table: o.table, Matched: matched}
+		if !opts.DryRun {
+			tr.Deleted, err = o.del(ctx)
+			if err != nil {
+				return Result{}, err
+			}
+			tr.Matched = tr.Deleted
+		}
+		res.Tables = append(res.Tables, tr)
+	}
+
+	// Tables that aren't tied to tracked PRs age out on their own.
+	aged := []struct {
+		table  string
+		cutoff time.Time
+		count  func(context.Context, time.Time) (int64, error)
+		del    func(context.Context, time.Time) (int64, error)
+	}{
+		{store.TableDeliveries, CutoffDateUTC(now, opts.ArchiveDays), st.CountDeliveriesOlderThanDate, st.DeleteDeliveriesOlderThanDate},
+		{store.TableFailedReactions, cutoff, st.CountFailedReactionsOlderThanDate, st.DeleteFailedReactionsOlderThanDate},
+		{store.TableQueuedReactions, cutoff, st.CountQueuedReactionsOlderThanDate, st.DeleteQueuedReactionsOlderThanDate},
+	}
+	for _, a := range aged {
+		matched, err := a.count(ctx, a.cutoff)
+		if err != nil {
+			return Result{}, err
+		}
+		tr := TableResult{Table: a.table, Matched: matched}
+		if !opts.DryRun && matched > 0 {
+			tr.Deleted, err = a.del(ctx, a.cutoff)
+			if err != nil {
+				return Result{}, err
+			}
+		}
+		res.Tables = append(res.Tables, tr)
+	}
+
+	for _, t := range res.Tables {
+		metrics.CleanupRowsDeleted.WithLabelValues(t.Table).Add(float64(t.Deleted))
+	}
+	res.DurationMS = time.Since(start).Milliseconds()
+	slog.InfoContext(ctx, "cleanup finished", "deleted", res.Deleted(), "duration_ms", res.DurationMS)
+	return res, nil
 }
@@ -3,53 +3,511 @@ package config
 import (
 	"errors"
 	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/adamantal/prmoji/internal/github"
+	"github.com/adamantal/prmoji/internal/quiet"
+	"github.com/adamantal/prmoji/internal/routing"
+	"github.com/adamantal/prmoji/internal/suppress"
+	"github.com/adamantal/prmoji/internal/tracing"
+	"github.com/adamantal/prmoji/internal/util"
+	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/viper"
 )
 
+type RetentionPolicy string
+
+const (
+	// RetentionInactivity forgets a PR once it had no Slack or GitHub activity for RetentionDays.
+	RetentionInactivity RetentionPolicy = "inactivity"
+	// RetentionInserted forgets each message RetentionDays after it was posted.
+	RetentionInserted RetentionPolicy = "inserted"
+)
+
+// ParseRetentionPolicy validates a RETENTION_POLICY value.
+func ParseRetentionPolicy(s string) (RetentionPolicy, error) {
+	switch p := RetentionPolicy(strings.ToLower(strings.TrimSpace(s))); p {
+	case RetentionInactivity, RetentionInserted:
+		return p, nil
+	default:
+		return "", fmt.Errorf("invalid RETENTION_POLICY: %q", s)
+	}
+}
+
+// SlackMode selects how Slack events reach prmoji.
+type SlackMode string
+
+const (
+	// SlackModeEvents receives events on the public POST /event/slack Request URL.
+	SlackModeEvents SlackMode = "events"
+	// SlackModeSocket receives events over a Socket Mode websocket opened with SLACK_APP_TOKEN.
+	SlackModeSocket SlackMode = "socket"
+)
+
 type Config struct {
-	SlackToken        string
-	Port              int
-	LogLevel          string
-	IgnoredCommenters []string
+	// ConfigFile is the config file the settings were read from, if any.
+	ConfigFile string
+	SlackToken string
+	// SlackSigningSecret verifies requests Slack sends to /event/slack and /command/slack; the
+	// command is disabled when empty.
+	SlackSigningSecret string
+	SlackMode          SlackMode
+	// SlackAppToken is the app-level token (xapp-...) Socket Mode connects with.
+	SlackAppToken string
+	// SlackClientID and SlackClientSecret enable installing prmoji to more workspaces through
+	// OAuth; SlackRedirectURL is the public URL of /slack/oauth/callback.
+	SlackClientID     string
+	SlackClientSecret string
+	SlackRedirectURL  string
+	// SlackAPIURL overrides the Slack Web API base URL, e.g. for a local Slack stand-in.
+	SlackAPIURL string
+	// SlackProxy is the proxy for Slack calls; empty uses HTTPS_PROXY and NO_PROXY.
+	SlackProxy string
+	// SlackCAFile is a PEM bundle of extra CAs trusted for Slack calls, e.g. a corporate CA.
+	SlackCAFile string
+	// SlackTimeout bounds each Slack Web API call.
+	SlackTimeout time.Duration
+	Port         int
+	LogLevel     string
+	LogFormat    string
+	// IgnoredCommenters suppress reactions to the actions of matching GitHub accounts; with
+	// IGNORE_BOTS set they start with a rule for bot comments.
+	IgnoredCommenters suppress.Rules
 	RetentionDays     int
-	DBPath            string
+	RetentionPolicy   RetentionPolicy
+	// GitHubRepos limits which repositories' GitHub events are processed.
+	GitHubRepos routing.RepoFilter
+	// ChannelRules limit which repositories' PR links are tracked in which channels.
+	ChannelRules routing.Rules
+	// RetentionChannelDays overrides RetentionDays for individual Slack channel IDs.
+	RetentionChannelDays map[string]int
+	CleanupMinDays       int
+	// BackfillOnStart makes startup scan channel history this far back for missed PR links; 0 disables it.
+	BackfillOnStart time.Duration
+	// ReactionRetryInterval is how often failed reactions are retried; 0 disables the retry loop.
+	ReactionRetryInterval time.Duration
+	// ReactionDebounce holds reactions back this long so that a burst of events on a PR
+	// collapses into one set of reactions; 0 reacts right away.
+	ReactionDebounce time.Duration
+	// QuietHours hold reactions back per channel until the window ends.
+	QuietHours quiet.Hours
+	// Emojis override the reaction added for some actions.
+	Emojis util.Emojis
+	// ReactionMaxAttempts is the number of attempts after which a failed reaction is given up on.
+	ReactionMaxAttempts int
+	// DryRun processes webhooks but only logs the Slack calls and store writes it would make.
+	DryRun bool
+	// ArchiveDays keeps raw webhook payloads for replay; 0 disables the archive.
+	ArchiveDays int
+	DBPath      string
+	// TracingExporter is one of none, stdout or otlp.
+	TracingExporter string
+	// TracingEndpoint is the OTLP/HTTP collector URL, e.g. http://otel-collector:4318.
+	TracingEndpoint string
+	// AdminToken enables the /admin/ API when set; requests must send it as a bearer token.
+	AdminToken string
+	// GitHubToken lets prmoji read PR state from the GitHub API to react to newly posted links
+	// right away; that lookup is disabled when empty.
+	GitHubToken string
+	// GitHubAppID and GitHubAppPrivateKey authenticate API calls as a GitHub App instead of
+	// with GitHubToken; the key is the app's PEM-encoded private key, whose newlines may
+	// be escaped as \n when stored on a single line.
+	GitHubAppID         int64
+	GitHubAppPrivateKey string
+	// GitHubAPIURL overrides the GitHub REST API base URL, e.g. https://github.example.com/api/v3/ for GHES.
+	GitHubAPIURL string
 }
 
+// Load reads the configuration from the environment and, when CONFIG_FILE is set, from that
+// file. Environment variables win over the file.
 func Load() (Config, error) {
+	return LoadFile(os.Getenv("CONFIG_FILE"))
+}
+
+// LoadFile is Load with the config file at path, or without one when path is empty. It reports
+// every invalid setting at once.
+func LoadFile(path string) (Config, error) {
+	v, err := newViper(path)
+	if err != nil {
+		return Config{}, err
+	}
+	return fromViper(v)
+}
+
+func newViper(path string) (*viper.Viper, error) {
 	v := viper.New()
 	v.AutomaticEnv()
 	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 
 	v.SetDefault("PORT", 5000)
 	v.SetDefault("LOG_LEVEL", "info")
+	v.SetDefault("LOG_FORMAT", "text")
+	v.SetDefault("SLACK_MODE", string(SlackModeEvents))
 	v.SetDefault("RETENTION_DAYS", 90)
+	v.SetDefault("RETENTION_POLICY", string(RetentionInactivity))
+	v.SetDefault("RETENTION_CHANNEL_DAYS", "")
+	v.SetDefault("CHANNEL_RULES", "")
+	v.SetDefault("GITHUB_REPOS", "")
+	v.SetDefault("CLEANUP_MIN_DAYS", 7)
+	v.SetDefault("ARCHIVE_DAYS", 0)
+	v.SetDefault("REACTION_RETRY_INTERVAL", "5m")
+	v.SetDefault("REACTION_DEBOUNCE", "0")
+	v.SetDefault("QUIET_HOURS", "")
+	v.SetDefault("BACKFILL_ON_START", "0")
+	v.SetDefault("REACTION_MAX_ATTEMPTS", 5)
 	v.SetDefault("DB_PATH", "./prmoji.db")
 	v.SetDefault("IGNORED_COMMENTERS", "")
+	v.SetDefault("IGNORE_BOTS", false)
+	v.SetDefault("TRACING_EXPORTER", "none")
+	v.SetDefault("GITHUB_API_URL", "https://api.github.com/")
+	v.SetDefault("EMOJIS", "")
+	v.SetDefault("DRY_RUN", false)
+	v.SetDefault("SLACK_API_URL", "https://slack.com/api/")
+	v.SetDefault("SLACK_TIMEOUT", "10s")
 
-	cfg := Config{
-		SlackToken:    v.GetString("SLACK_TOKEN"),
-		Port:          v.GetInt("PORT"),
-		LogLevel:      v.GetString("LOG_LEVEL"),
-		RetentionDays: v.GetInt("RETENTION_DAYS"),
-		DBPath:        v.GetString("DB_PATH"),
+	if path != "" {
+		v.SetConfigFile(path)
+		if err := v.ReadInConfig(); err != nil {
+			return nil, fmt.Errorf("read config file: %w", err)
+		}
 	}
+	return v, nil
+}
+
+// settings lists every setting by its environment variable name, the names a config file
+// may use lowercased.
+var settings = []string{
+	"SLACK_TOKEN", "SLACK_SIGNING_SECRET", "SLACK_MODE", "SLACK_APP_TOKEN", "SLACK_CLIENT_ID",
+	"SLACK_CLIENT_SECRET", "SLACK_REDIRECT_URL", "SLACK_API_URL", "SLACK_PROXY", "SLACK_CA_FILE",
+	"SLACK_TIMEOUT", "PORT", "LOG_LEVEL", "LOG_FORMAT", "RETENTION_DAYS", "RETENTION_POLICY",
+	"RETENTION_CHANNEL_DAYS", "CLEANUP_MIN_DAYS", "ARCHIVE_DAYS", "CHANNEL_RULES", "GITHUB_REPOS",
+	"IGNORED_COMMENTERS", "IGNORE_BOTS", "EMOJIS", "REACTION_RETRY_INTERVAL", "REACTION_DEBOUNCE",
+	"REACTION_MAX_ATTEMPTS", "QUIET_HOURS", "BACKFILL_ON_START", "DRY_RUN", "DB_PATH", "ADMIN_TOKEN",
+	"TRACING_EXPORTER", "TRACING_ENDPOINT", "GITHUB_TOKEN", "GITHUB_APP_ID", "GITHUB_APP_PRIVATE_KEY",
+	"GITHUB_API_URL",
+}
 
-	cfg.IgnoredCommenters = strings.Split(v.GetString("IGNORED_COMMENTERS"), ",")
+// unknownKeys returns the config file keys that aren't settings, e.g. misspelled ones, which
+// would otherwise be ignored silently. Keys inside a map-valued setting aren't checked.
+func unknownKeys(v *viper.Viper) []string {
+	var out []string
+	for _, key := range v.AllKeys() {
+		name, _, _ := strings.Cut(key, ".")
+		if !slices.Contains(settings, strings.ToUpper(name)) && !slices.Contains(out, name) {
+			out = append(out, name)
+		}
+	}
+	slices.Sort(out)
+	return out
+}
 
-	if strings.TrimSpace(cfg.SlackToken) == "" {
-		return Config{}, errors.New("SLACK_TOKEN is required")
+func fromViper(v *viper.Viper) (Config, error) {
+	var errs []error
+	for _, key := range unknownKeys(v) {
+		errs = append(errs, fmt.Errorf("unknown setting in %s: %q", v.ConfigFileUsed(), key))
+	}
+	cfg := Config{
+		ConfigFile:          v.ConfigFileUsed(),
+		SlackToken:          v.GetString("SLACK_TOKEN"),
+		SlackSigningSecret:  strings.TrimSpace(v.GetString("SLACK_SIGNING_SECRET")),
+		SlackMode:           SlackMode(strings.ToLower(strings.TrimSpace(v.GetString("SLACK_MODE")))),
+		SlackAppToken:       strings.TrimSpace(v.GetString("SLACK_APP_TOKEN")),
+		SlackClientID:       strings.TrimSpace(v.GetString("SLACK_CLIENT_ID")),
+		SlackClientSecret:   strings.TrimSpace(v.GetString("SLACK_CLIENT_SECRET")),
+		SlackRedirectURL:    strings.TrimSpace(v.GetString("SLACK_REDIRECT_URL")),
+		SlackAPIURL:         strings.TrimSpace(v.GetString("SLACK_API_URL")),
+		SlackProxy:          strings.TrimSpace(v.GetString("SLACK_PROXY")),
+		SlackCAFile:         strings.TrimSpace(v.GetString("SLACK_CA_FILE")),
+		Port:                v.GetInt("PORT"),
+		LogLevel:            v.GetString("LOG_LEVEL"),
+		LogFormat:           strings.ToLower(strings.TrimSpace(v.GetString("LOG_FORMAT"))),
+		RetentionDays:       v.GetInt("RETENTION_DAYS"),
+		CleanupMinDays:      v.GetInt("CLEANUP_MIN_DAYS"),
+		ArchiveDays:         v.GetInt("ARCHIVE_DAYS"),
+		ReactionMaxAttempts: v.GetInt("REACTION_MAX_ATTEMPTS"),
+		DryRun:              v.GetBool("DRY_RUN"),
+		DBPath:              v.GetString("DB_PATH"),
+		AdminToken:          strings.TrimSpace(v.GetString("ADMIN_TOKEN")),
+		TracingEndpoint:     strings.TrimSpace(v.GetString("TRACING_ENDPOINT")),
+		GitHubToken:         strings.TrimSpace(v.GetString("GITHUB_TOKEN")),
+		GitHubAppID:         v.GetInt64("GITHUB_APP_ID"),
+		GitHubAppPrivateKey: strings.TrimSpace(strings.ReplaceAll(v.GetString("GITHUB_APP_PRIVATE_KEY"), `\n`, "\n")),
+		GitHubAPIURL:        strings.TrimSpace(v.GetString("GITHUB_API_URL")),
+	}
+
+	if (cfg.SlackClientID == "") != (cfg.SlackClientSecret == "") {
+		errs = append(errs, errors.New("SLACK_CLIENT_ID and SLACK_CLIENT_SECRET must be set together"))
+	}
+	if cfg.SlackClientID != "" && cfg.SlackSigningSecret == "" {
+		errs = append(errs, errors.New("SLACK_SIGNING_SECRET is required when SLACK_CLIENT_ID is set"))
+	}
+	if strings.TrimSpace(cfg.SlackToken) == "" && cfg.SlackClientID == "" {
+		errs = append(errs, errors.New("SLACK_TOKEN is required unless SLACK_CLIENT_ID is set"))
+	}
+	switch cfg.SlackMode {
+	case SlackModeEvents:
+	case SlackModeSocket:
+		if cfg.SlackAppToken == "" {
+			errs = append(errs, errors.New("SLACK_APP_TOKEN is required with SLACK_MODE=socket"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("invalid SLACK_MODE: %q", cfg.SlackMode))
 	}
 	if cfg.Port <= 0 || cfg.Port > 65535 {
-		return Config{}, fmt.Errorf("invalid PORT: %d", cfg.Port)
+		errs = append(errs, fmt.Errorf("invalid PORT: %d", cfg.Port))
+	}
+	if cfg.LogFormat != "text" && cfg.LogFormat != "json" {
+		errs = append(errs, fmt.Errorf("invalid LOG_FORMAT: %q", cfg.LogFormat))
 	}
 	if cfg.RetentionDays <= 0 {
-		return Config{}, fmt.Errorf("invalid RETENTION_DAYS: %d", cfg.RetentionDays)
+		errs = append(errs, fmt.Errorf("invalid RETENTION_DAYS: %d", cfg.RetentionDays))
+	}
+	policy, err := ParseRetentionPolicy(v.GetString("RETENTION_POLICY"))
+	if err != nil {
+		errs = append(errs, err)
+	}
+	cfg.RetentionPolicy = policy
+	channelDays, err := parseChannelDays(setting(v, "RETENTION_CHANNEL_DAYS", ",", channelDaysEntry), cfg.CleanupMinDays)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	cfg.RetentionChannelDays = channelDays
+	rules, err := routing.Parse(setting(v, "CHANNEL_RULES", ";", channelRuleEntry))
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid CHANNEL_RULES: %w", err))
+	}
+	cfg.ChannelRules = rules
+	repos, err := routing.ParseRepoFilter(setting(v, "GITHUB_REPOS", ",", nil))
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid GITHUB_REPOS: %w", err))
+	}
+	cfg.GitHubRepos = repos
+	ignored := setting(v, "IGNORED_COMMENTERS", ",", nil)
+	if v.GetBool("IGNORE_BOTS") {
+		ignored = suppress.Bots + "," + ignored
+	}
+	ignoredCommenters, err := suppress.Parse(ignored)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid IGNORED_COMMENTERS: %w", err))
+	}
+	cfg.IgnoredCommenters = ignoredCommenters
+	exporter, err := tracing.ParseExporter(v.GetString("TRACING_EXPORTER"))
+	if err != nil {
+		errs = append(errs, err)
+	}
+	cfg.TracingExporter = exporter
+	if cfg.CleanupMinDays <= 0 {
+		errs = append(errs, fmt.Errorf("invalid CLEANUP_MIN_DAYS: %d", cfg.CleanupMinDays))
+	}
+	// The scheduled cleanup uses RETENTION_DAYS as is, so it must respect the same floor as POST /cleanup/.
+	if cfg.RetentionDays > 0 && cfg.RetentionDays < cfg.CleanupMinDays {
+		errs = append(errs, fmt.Errorf("RETENTION_DAYS (%d) must be at least CLEANUP_MIN_DAYS (%d)", cfg.RetentionDays, cfg.CleanupMinDays))
+	}
+	if cfg.ArchiveDays < 0 {
+		errs = append(errs, fmt.Errorf("invalid ARCHIVE_DAYS: %d", cfg.ArchiveDays))
+	}
+	backfillOnStart, err := time.ParseDuration(strings.TrimSpace(v.GetString("BACKFILL_ON_START")))
+	if err != nil || backfillOnStart < 0 {
+		errs = append(errs, fmt.Errorf("invalid BACKFILL_ON_START: %q", v.GetString("BACKFILL_ON_START")))
 	}
+	cfg.BackfillOnStart = backfillOnStart
+	retryInterval, err := time.ParseDuration(strings.TrimSpace(v.GetString("REACTION_RETRY_INTERVAL")))
+	if err != nil || retryInterval < 0 {
+		errs = append(errs, fmt.Errorf("invalid REACTION_RETRY_INTERVAL: %q", v.GetString("REACTION_RETRY_INTERVAL")))
+	}
+	cfg.ReactionRetryInterval = retryInterval
+	debounce, err := time.ParseDuration(strings.TrimSpace(v.GetString("REACTION_DEBOUNCE")))
+	if err != nil || debounce < 0 {
+		errs = append(errs, fmt.Errorf("invalid REACTION_DEBOUNCE: %q", v.GetString("REACTION_DEBOUNCE")))
+	}
+	cfg.ReactionDebounce = debounce
+	quietHours, err := quiet.Parse(setting(v, "QUIET_HOURS", ";", quietHoursEntry))
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid QUIET_HOURS: %w", err))
+	}
+	cfg.QuietHours = quietHours
+	emojis, err := parseEmojis(setting(v, "EMOJIS", ",", nil))
+	if err != nil {
+		errs = append(errs, err)
+	}
+	cfg.Emojis = emojis
+	if cfg.ReactionMaxAttempts <= 0 {
+		errs = append(errs, fmt.Errorf("invalid REACTION_MAX_ATTEMPTS: %d", cfg.ReactionMaxAttempts))
+	}
+	if cfg.GitHubAppID < 0 || (cfg.GitHubAppID == 0) != (cfg.GitHubAppPrivateKey == "") {
+		errs = append(errs, errors.New("GITHUB_APP_ID and GITHUB_APP_PRIVATE_KEY must be set together"))
+	}
+	if cfg.GitHubAppID != 0 && cfg.GitHubToken != "" {
+		errs = append(errs, errors.New("set either GITHUB_TOKEN or GITHUB_APP_ID, not both"))
+	}
+	if u, err := url.Parse(cfg.GitHubAPIURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("invalid GITHUB_API_URL: %q", cfg.GitHubAPIURL))
+	}
+	if u, err := url.Parse(cfg.SlackAPIURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("invalid SLACK_API_URL: %q", cfg.SlackAPIURL))
+	}
+	if u, err := url.Parse(cfg.SlackProxy); cfg.SlackProxy != "" && (err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "") {
+		errs = append(errs, fmt.Errorf("invalid SLACK_PROXY: %q", cfg.SlackProxy))
+	}
+	if _, err := os.Stat(cfg.SlackCAFile); cfg.SlackCAFile != "" && err != nil {
+		errs = append(errs, fmt.Errorf("invalid SLACK_CA_FILE: %w", err))
+	}
+	slackTimeout, err := time.ParseDuration(strings.TrimSpace(v.GetString("SLACK_TIMEOUT")))
+	if err != nil || slackTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("invalid SLACK_TIMEOUT: %q", v.GetString("SLACK_TIMEOUT")))
+	}
+	cfg.SlackTimeout = slackTimeout
 	if strings.TrimSpace(cfg.DBPath) == "" {
-		return Config{}, errors.New("DB_PATH cannot be empty")
+		errs = append(errs, errors.New("DB_PATH cannot be empty"))
 	}
 
+	if err := errors.Join(errs...); err != nil {
+		return Config{}, err
+	}
 	return cfg, nil
 }
+
+// parseChannelDays parses a comma-separated list of CHANNEL_ID=DAYS pairs; like RETENTION_DAYS,
+// no override may go below minDays.
+func parseChannelDays(s string, minDays int) (map[string]int, error) {
+	out := map[string]int{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		channel, rawDays, ok := strings.Cut(pair, "=")
+		channel = strings.TrimSpace(channel)
+		days, err := strconv.Atoi(strings.TrimSpace(rawDays))
+		if !ok || channel == "" || err != nil || days <= 0 {
+			return nil, fmt.Errorf("invalid RETENTION_CHANNEL_DAYS entry: %q", pair)
+		}
+		if days < minDays {
+			return nil, fmt.Errorf("invalid RETENTION_CHANNEL_DAYS entry %q: must be at least CLEANUP_MIN_DAYS (%d)", pair, minDays)
+		}
+		// Channel IDs are upper case; config file map keys arrive lowercased.
+		out[strings.ToUpper(channel)] = days
+	}
+	return out, nil
+}
+
+// parseEmojis parses a comma-separated list of ACTION=EMOJI pairs.
+func parseEmojis(s string) (util.Emojis, error) {
+	out := util.Emojis{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		action, emoji, ok := strings.Cut(pair, "=")
+		a := github.Action(strings.ToLower(strings.TrimSpace(action)))
+		emoji = strings.Trim(strings.TrimSpace(emoji), ":")
+		if !ok || !slices.Contains(github.Actions, a) || emoji == "" {
+			return nil, fmt.Errorf("invalid EMOJIS entry: %q", pair)
+		}
+		out[a] = emoji
+	}
+	return out, nil
+}
+
+// setting reads a setting in the string syntax of its environment variable. A config file may
+// also write it as a list, whose items are joined with sep, or as a map of KEY=VALUE items.
+// Maps inside a list are turned into an item by entry.
+func setting(v *viper.Viper, key, sep string, entry func(map[string]any) string) string {
+	switch x := v.Get(key).(type) {
+	case []any:
+		items := make([]string, 0, len(x))
+		for _, item := range x {
+			if m, ok := item.(map[string]any); ok && entry != nil {
+				items = append(items, entry(m))
+				continue
+			}
+			items = append(items, fmt.Sprint(item))
+		}
+		return strings.Join(items, sep)
+	case map[string]any:
+		items := make([]string, 0, len(x))
+		for k, val := range x {
+			items = append(items, k+"="+fmt.Sprint(val))
+		}
+		slices.Sort(items)
+		return strings.Join(items, sep)
+	default:
+		return v.GetString(key)
+	}
+}
+
+// channelRuleEntry reads `{channel: C0DEPS, repos: [acme/infra-*]}`.
+func channelRuleEntry(m map[string]any) string {
+	return fmt.Sprint(m["channel"]) + "=" + joinList(m["repos"], ",")
+}
+
+// channelDaysEntry reads `{channel: C0DEPS, days: 30}`.
+func channelDaysEntry(m map[string]any) string {
+	return fmt.Sprint(m["channel"]) + "=" + fmt.Sprint(m["days"])
+}
+
+// quietHoursEntry reads `{channel: "*", start: "22:00", end: "07:00", zone: Europe/Budapest}`.
+func quietHoursEntry(m map[string]any) string {
+	s := fmt.Sprintf("%v=%v-%v", m["channel"], m["start"], m["end"])
+	if zone, ok := m["zone"]; ok {
+		s += "@" + fmt.Sprint(zone)
+	}
+	return s
+}
+
+func joinList(v any, sep string) string {
+	items, ok := v.([]any)
+	if !ok {
+		return fmt.Sprint(v)
+	}
+	parts := make([]string, 0, len(items))
+	for _, item := range items {
+		parts = append(parts, fmt.Sprint(item))
+	}
+	return strings.Join(parts, sep)
+}
+
+// Reloaded returns c with the settings of next that are safe to change while prmoji runs:
+// event filtering, suppression, routing, emoji, reaction timing, retention and dry-run mode. Credentials,
+// listeners, storage and background loop intervals keep their startup values.
+func (c Config) Reloaded(next Config) Config {
+	c.GitHubRepos = next.GitHubRepos
+	c.ChannelRules = next.ChannelRules
+	c.IgnoredCommenters = next.IgnoredCommenters
+	c.Emojis = next.Emojis
+	c.ReactionDebounce = next.ReactionDebounce
+	c.QuietHours = next.QuietHours
+	c.ReactionMaxAttempts = next.ReactionMaxAttempts
+	c.RetentionDays = next.RetentionDays
+	c.RetentionPolicy = next.RetentionPolicy
+	c.RetentionChannelDays = next.RetentionChannelDays
+	c.CleanupMinDays = next.CleanupMinDays
+	c.ArchiveDays = next.ArchiveDays
+	c.DryRun = next.DryRun
+	return c
+}
+
+// Watch reloads the config file at path whenever it changes and passes the new configuration to
+// onChange, or its errors to onError; the previous configuration then stays in effect.
+func Watch(path string, onChange func(Config), onError func(error)) error {
+	v, err := newViper(path)
+	if err != nil {
+		return err
+	}
+	v.OnConfigChange(func(fsnotify.Event) {
+		cfg, err := fromViper(v)
+		if err != nil {
+			onError(err)
+			return
+		}
+		onChange(cfg)
+	})
+	v.WatchConfig()
+	return nil
+}
@@ -0,0 +1,292 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adamantal/prmoji/internal/github"
+)
+
+func TestLoadReadsEnv(t *testing.T) {
+	t.Setenv("SLACK_TOKEN", "xoxb-test")
+	t.Setenv("LOG_FORMAT", "json")
+	t.Setenv("ARCHIVE_DAYS", "14")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.LogFormat != "json" {
+		t.Fatalf("expected LOG_FORMAT json got %q", cfg.LogFormat)
+	}
+	if cfg.ArchiveDays != 14 {
+		t.Fatalf("expected ARCHIVE_DAYS 14 got %d", cfg.ArchiveDays)
+	}
+}
+
+func TestLoadDefaults(t *testing.T) {
+	t.Setenv("SLACK_TOKEN", "xoxb-test")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.LogFormat != "text" || cfg.RetentionDays != 90 || cfg.RetentionPolicy != RetentionInactivity || cfg.ArchiveDays != 0 {
+		t.Fatalf("unexpected defaults: %+v", cfg)
+	}
+}
+
+func TestLoadSlackTransport(t *testing.T) {
+	t.Setenv("SLACK_TOKEN", "xoxb-test")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.SlackAPIURL != "https://slack.com/api/" || cfg.SlackProxy != "" || cfg.SlackCAFile != "" || cfg.SlackTimeout != 10*time.Second {
+		t.Fatalf("unexpected defaults: %+v", cfg)
+	}
+
+	t.Setenv("SLACK_API_URL", "ftp://slack.local")
+	t.Setenv("SLACK_PROXY", "proxy:3128")
+	t.Setenv("SLACK_CA_FILE", filepath.Join(t.TempDir(), "missing.pem"))
+	t.Setenv("SLACK_TIMEOUT", "0")
+	_, err = Load()
+	for _, name := range []string{"SLACK_API_URL", "SLACK_PROXY", "SLACK_CA_FILE", "SLACK_TIMEOUT"} {
+		if err == nil || !strings.Contains(err.Error(), name) {
+			t.Fatalf("expected %s to be rejected, got %v", name, err)
+		}
+	}
+}
+
+func TestLoadIgnoredCommenters(t *testing.T) {
+	t.Setenv("SLACK_TOKEN", "xoxb-test")
+	t.Setenv("IGNORED_COMMENTERS", " Bob ,{author}:*")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(cfg.IgnoredCommenters) != 2 || cfg.IgnoredCommenters[0].Who != "bob" {
+		t.Fatalf("unexpected rules: %+v", cfg.IgnoredCommenters)
+	}
+
+	t.Setenv("IGNORE_BOTS", "true")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(cfg.IgnoredCommenters) != 3 || cfg.IgnoredCommenters[0].Who != "{bot}" || cfg.IgnoredCommenters[1].Who != "bob" {
+		t.Fatalf("expected a bot rule in front, got %+v", cfg.IgnoredCommenters)
+	}
+
+	t.Setenv("IGNORED_COMMENTERS", "bob:reviewed")
+	if _, err := Load(); err == nil {
+		t.Fatalf("expected an unknown action to be rejected")
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prmoji.yaml")
+	file := `
+slack_token: xoxb-file
+retention_days: 30
+channel_rules:
+  - channel: C0DEPS
+    repos: [acme/infra-*]
+  - "#eng-*=acme/*"
+retention_channel_days:
+  - channel: C0DEPS
+    days: 7
+quiet_hours:
+  - {channel: "*", start: "22:00", end: "07:00", zone: Europe/Budapest}
+github_repos: [acme/*, "!acme/archive-*"]
+emojis:
+  approved: ":thumbsup:"
+`
+	if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	t.Setenv("RETENTION_DAYS", "60")
+
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.SlackToken != "xoxb-file" || cfg.ConfigFile != path {
+		t.Fatalf("expected settings from the file, got %+v", cfg)
+	}
+	if cfg.RetentionDays != 60 {
+		t.Fatalf("expected the environment to win, got RETENTION_DAYS %d", cfg.RetentionDays)
+	}
+	if len(cfg.ChannelRules) != 2 || cfg.ChannelRules[0].Channel != "c0deps" || cfg.ChannelRules[1].Allow[0] != "acme/*" {
+		t.Fatalf("unexpected channel rules: %+v", cfg.ChannelRules)
+	}
+	if cfg.RetentionChannelDays["C0DEPS"] != 7 || len(cfg.QuietHours) != 1 || len(cfg.GitHubRepos.Deny) != 1 {
+		t.Fatalf("unexpected structured settings: %+v", cfg)
+	}
+	if got := cfg.Emojis.For(github.ActionApproved); got != "thumbsup" {
+		t.Fatalf("expected approved emoji thumbsup got %q", got)
+	}
+	if got := cfg.Emojis.For(github.ActionMerged); got != "pr-merged" {
+		t.Fatalf("expected the default merged emoji got %q", got)
+	}
+
+	file = `
+slack_token: xoxb-file
+retention_channel_days: {C0DEPS: 7}
+`
+	if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	cfg, err = LoadFile(path)
+	if err != nil {
+		t.Fatalf("load map form: %v", err)
+	}
+	if len(cfg.RetentionChannelDays) != 1 || cfg.RetentionChannelDays["C0DEPS"] != 7 {
+		t.Fatalf("unexpected channel retention from the map form: %+v", cfg.RetentionChannelDays)
+	}
+}
+
+func TestLoadFileUnknownKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prmoji.yaml")
+	file := `
+slack_token: xoxb-file
+retention_dasy: 30
+emojis:
+  approved: thumbsup
+quiet_hour:
+  c0deps: "22:00-07:00"
+`
+	if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	_, err := LoadFile(path)
+	if err == nil {
+		t.Fatalf("expected the misspelled keys to be reported")
+	}
+	for _, want := range []string{`"retention_dasy"`, `"quiet_hour"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %s to be reported in %q", want, err)
+		}
+	}
+	if strings.Contains(err.Error(), "emojis") {
+		t.Fatalf("a known map-valued setting must not be reported: %q", err)
+	}
+}
+
+func TestLoadReportsAllErrors(t *testing.T) {
+	t.Setenv("SLACK_TOKEN", "xoxb-test")
+	t.Setenv("PORT", "0")
+	t.Setenv("REACTION_DEBOUNCE", "soon")
+	t.Setenv("EMOJIS", "reviewed=eyes")
+
+	_, err := Load()
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	for _, want := range []string{"PORT", "REACTION_DEBOUNCE", "EMOJIS"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %s to be reported in %q", want, err)
+		}
+	}
+}
+
+func TestReloaded(t *testing.T) {
+	cfg := Config{SlackToken: "xoxb-old", Port: 5000, ReactionDebounce: 0}
+	next := Config{SlackToken: "xoxb-new", Port: 6000, ReactionDebounce: time.Minute, DryRun: true}
+	got := cfg.Reloaded(next)
+	if got.SlackToken != "xoxb-old" || got.Port != 5000 || got.ReactionDebounce != time.Minute || !got.DryRun {
+		t.Fatalf("unexpected reloaded config: %+v", got)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prmoji.yaml")
+	if err := os.WriteFile(path, []byte("slack_token: xoxb-test\nreaction_debounce: 10s\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	// One write can fire several events, some seeing a truncated file; never block the watcher.
+	changes := make(chan Config, 10)
+	failures := make(chan error, 10)
+	onChange := func(c Config) {
+		select {
+		case changes <- c:
+		default:
+		}
+	}
+	onError := func(err error) {
+		select {
+		case failures <- err:
+		default:
+		}
+	}
+	if err := Watch(path, onChange, onError); err != nil {
+		t.Fatalf("watch: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("slack_token: xoxb-test\nreaction_debounce: never\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	select {
+	case <-failures:
+	case c := <-changes:
+		t.Fatalf("expected the invalid change to be rejected, got %+v", c)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no reload")
+	}
+
+	if err := os.WriteFile(path, []byte("slack_token: xoxb-test\nreaction_debounce: 30s\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case c := <-changes:
+			if c.ReactionDebounce == 30*time.Second {
+				return
+			}
+		case <-failures:
+		case <-timeout:
+			t.Fatalf("no reload")
+		}
+	}
+}
+
+func TestLoadSlackClientRequiresSigningSecret(t *testing.T) {
+	t.Setenv("SLACK_CLIENT_ID", "123.456")
+	t.Setenv("SLACK_CLIENT_SECRET", "client-secret")
+
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "SLACK_SIGNING_SECRET") {
+		t.Fatalf("expected SLACK_SIGNING_SECRET to be required, got %v", err)
+	}
+
+	t.Setenv("SLACK_SIGNING_SECRET", "signing-secret")
+	if _, err := Load(); err != nil {
+		t.Fatalf("expected the config to load, got %v", err)
+	}
+}
+
+func TestLoadRetentionBelowCleanupMinDays(t *testing.T) {
+	t.Setenv("SLACK_TOKEN", "xoxb-test")
+	t.Setenv("RETENTION_DAYS", "1")
+	t.Setenv("CLEANUP_MIN_DAYS", "7")
+
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "CLEANUP_MIN_DAYS") {
+		t.Fatalf("expected RETENTION_DAYS below CLEANUP_MIN_DAYS to be rejected, got %v", err)
+	}
+
+	t.Setenv("RETENTION_DAYS", "7")
+	if _, err := Load(); err != nil {
+		t.Fatalf("expected RETENTION_DAYS equal to CLEANUP_MIN_DAYS to load, got %v", err)
+	}
+
+	t.Setenv("RETENTION_CHANNEL_DAYS", "C0ARCH=180,C0DEPS=1")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "C0DEPS=1") {
+		t.Fatalf("expected a channel override below CLEANUP_MIN_DAYS to be rejected, got %v", err)
+	}
+}
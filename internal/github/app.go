@@ -0,0 +1,166 @@
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// appJWTLifetime stays under GitHub's 10 minute limit; iat is backdated for clock drift.
+	appJWTLifetime = 9 * time.Minute
+	appJWTBackdate = time.Minute
+	// tokenRefreshMargin renews an installation token this long before it expires.
+	tokenRefreshMargin = 5 * time.Minute
+)
+
+// NewAppClient returns a client that authenticates as a GitHub App: it signs a JWT with
+// the app's private key (PEM, PKCS#1 or PKCS#8), looks up the app's installation for the
+// owner of each repository and calls the API with that installation's access token.
+func NewAppClient(appID int64, privateKeyPEM []byte, baseURL string) (*Client, error) {
+	key, err := ParsePrivateKey(privateKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+	app := &appAuth{
+		appID:         appID,
+		key:           key,
+		now:           time.Now,
+		installations: map[string]int64{},
+		tokens:        map[int64]installationToken{},
+	}
+	c := newClient(app, baseURL)
+	app.api = c
+	return c, nil
+}
+
+// ParsePrivateKey decodes a GitHub App private key.
+func ParsePrivateKey(pemBytes []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("github app private key: no PEM block found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("github app private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("github app private key: not an RSA key")
+	}
+	return key, nil
+}
+
+type installationToken struct {
+	token     string
+	expiresAt time.Time
+}
+
+// appAuth hands out installation access tokens, caching installation IDs per owner and
+// tokens per installation. Its own calls go through api, authenticated with the app JWT.
+type appAuth struct {
+	appID int64
+	key   *rsa.PrivateKey
+	api   *Client
+	now   func() time.Time
+
+	// mu is held across API calls so concurrent requests don't mint duplicate tokens.
+	mu            sync.Mutex
+	installations map[string]int64
+	tokens        map[int64]installationToken
+}
+
+func (a *appAuth) token(ctx context.Context, owner string) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	owner = strings.ToLower(owner)
+	id, ok := a.installations[owner]
+	if !ok {
+		var err error
+		if id, err = a.installationID(ctx, owner); err != nil {
+			return "", err
+		}
+		a.installations[owner] = id
+	}
+
+	if t, ok := a.tokens[id]; ok && a.now().Add(tokenRefreshMargin).Before(t.expiresAt) {
+		return t.token, nil
+	}
+	jwt, err := a.jwt()
+	if err != nil {
+		return "", err
+	}
+	var out struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := a.api.do(ctx, http.MethodPost, fmt.Sprintf("app/installations/%d/access_tokens", id), jwt, &out); err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			// The app was uninstalled; look the owner up again next time.
+			delete(a.installations, owner)
+		}
+		return "", fmt.Errorf("create installation token: %w", err)
+	}
+	a.tokens[id] = installationToken{token: out.Token, expiresAt: out.ExpiresAt}
+	return out.Token, nil
+}
+
+// installationID finds the app's installation on an organization or user account.
+func (a *appAuth) installationID(ctx context.Context, owner string) (int64, error) {
+	jwt, err := a.jwt()
+	if err != nil {
+		return 0, err
+	}
+	var out struct {
+		ID int64 `json:"id"`
+	}
+	err = a.api.do(ctx, http.MethodGet, "orgs/"+url.PathEscape(owner)+"/installation", jwt, &out)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		err = a.api.do(ctx, http.MethodGet, "users/"+url.PathEscape(owner)+"/installation", jwt, &out)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("find installation for %s: %w", owner, err)
+	}
+	return out.ID, nil
+}
+
+// jwt returns an RS256 JSON Web Token identifying the app.
+func (a *appAuth) jwt() (string, error) {
+	now := a.now()
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]any{
+		"iat": now.Add(-appJWTBackdate).Unix(),
+		"exp": now.Add(appJWTLifetime).Unix(),
+		"iss": strconv.FormatInt(a.appID, 10),
+	})
+	if err != nil {
+		return "", err
+	}
+	signed := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, a.key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", fmt.Errorf("sign app jwt: %w", err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
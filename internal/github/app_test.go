@@ -0,0 +1,134 @@
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeGitHubApp serves the app endpoints for org "o" (installation 1) and user "u"
+// (installation 2), plus one PR per owner that only accepts that owner's token.
+func fakeGitHubApp(t *testing.T, pub *rsa.PublicKey, now func() time.Time, minted *atomic.Int32) *httptest.Server {
+	t.Helper()
+	checkJWT := func(r *http.Request) bool {
+		parts := strings.Split(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), ".")
+		if len(parts) != 3 {
+			return false
+		}
+		sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+		if err != nil {
+			return false
+		}
+		sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		if rsa.VerifyPKCS1v15(pub, crypto.SHA256, sum[:], sig) != nil {
+			return false
+		}
+		claims, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		var c struct {
+			Iss string `json:"iss"`
+		}
+		return json.Unmarshal(claims, &c) == nil && c.Iss == "42"
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/orgs/") || strings.HasPrefix(r.URL.Path, "/users/") || strings.HasPrefix(r.URL.Path, "/app/"):
+			if !checkJWT(r) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		}
+		switch {
+		case r.URL.Path == "/orgs/o/installation":
+			_, _ = w.Write([]byte(`{"id":1}`))
+		case r.URL.Path == "/users/u/installation":
+			_, _ = w.Write([]byte(`{"id":2}`))
+		case strings.HasPrefix(r.URL.Path, "/app/installations/") && r.Method == http.MethodPost:
+			n := minted.Add(1)
+			id := strings.Split(r.URL.Path, "/")[3]
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"token":      fmt.Sprintf("ghs-%s-%d", id, n),
+				"expires_at": now().Add(time.Hour).UTC().Format(time.RFC3339),
+			})
+		case r.URL.Path == "/repos/o/r/pulls/1" || r.URL.Path == "/repos/u/r/pulls/1":
+			want := "Bearer ghs-1-"
+			if strings.HasPrefix(r.URL.Path, "/repos/u/") {
+				want = "Bearer ghs-2-"
+			}
+			if !strings.HasPrefix(auth, want) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, _ = w.Write([]byte(`{"state":"open","merged":false}`))
+		case strings.HasSuffix(r.URL.Path, "/reviews"):
+			_, _ = w.Write([]byte(`[]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message":"Not Found"}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestAppClient(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	now := time.Now()
+	clock := func() time.Time { return now }
+	var minted atomic.Int32
+	srv := fakeGitHubApp(t, &key.PublicKey, clock, &minted)
+	c, err := NewAppClient(42, keyPEM, srv.URL)
+	if err != nil {
+		t.Fatalf("new app client: %v", err)
+	}
+	c.auth.(*appAuth).now = clock
+	ctx := context.Background()
+
+	t.Run("uses the owner's installation and caches its token", func(t *testing.T) {
+		for _, owner := range []string{"o", "o", "u"} {
+			if _, err := c.PullRequestState(ctx, PRRef{Owner: owner, Repo: "r", Number: 1}); err != nil {
+				t.Fatalf("%s: unexpected err: %v", owner, err)
+			}
+		}
+		if got := minted.Load(); got != 2 {
+			t.Fatalf("expected 2 tokens minted got %d", got)
+		}
+	})
+
+	t.Run("refreshes tokens before they expire", func(t *testing.T) {
+		now = now.Add(56 * time.Minute)
+		if _, err := c.PullRequestState(ctx, PRRef{Owner: "o", Repo: "r", Number: 1}); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if got := minted.Load(); got != 3 {
+			t.Fatalf("expected a refreshed token got %d minted", got)
+		}
+	})
+
+	t.Run("reports owners without an installation", func(t *testing.T) {
+		_, err := c.PullRequestState(ctx, PRRef{Owner: "nobody", Repo: "r", Number: 1})
+		if err == nil || !strings.Contains(err.Error(), "find installation for nobody") {
+			t.Fatalf("expected installation lookup error got %v", err)
+		}
+	})
+}
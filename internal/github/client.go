@@ -0,0 +1,247 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adamantal/prmoji/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const DefaultBaseURL = "https://api.github.com/"
+
+// reviewsPerPage is the page size for listing reviews; GitHub caps it at 100.
+const reviewsPerPage = 100
+
+// Client is a minimal GitHub REST API client for reading pull request state.
+type Client struct {
+	auth    tokenSource
+	baseURL string
+	hc      *http.Client
+}
+
+// tokenSource returns the token to call the API with for a repository of owner.
+type tokenSource interface {
+	token(ctx context.Context, owner string) (string, error)
+}
+
+type staticToken string
+
+func (t staticToken) token(context.Context, string) (string, error) { return string(t), nil }
+
+// NewClient returns a client authenticating with token (anonymous when empty). baseURL
+// overrides https://api.github.com/, e.g. https://github.example.com/api/v3/ for GHES.
+func NewClient(token, baseURL string) *Client {
+	return newClient(staticToken(token), baseURL)
+}
+
+func newClient(auth tokenSource, baseURL string) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+	return &Client{
+		auth:    auth,
+		baseURL: baseURL,
+		hc: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+// APIError is a non-2xx response from the GitHub REST API.
+type APIError struct {
+	Path       string
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("github %s: http %d: %s", e.Path, e.StatusCode, e.Message)
+}
+
+// PRRef identifies a pull request.
+type PRRef struct {
+	Owner  string
+	Repo   string
+	Number int
+}
+
+// ParsePRURL splits a https://<host>/<owner>/<repo>/pull/<number> URL.
+func ParsePRURL(prURL string) (PRRef, bool) {
+	u, err := url.Parse(prURL)
+	if err != nil {
+		return PRRef{}, false
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 4 || parts[2] != "pull" || parts[0] == "" || parts[1] == "" {
+		return PRRef{}, false
+	}
+	n, err := strconv.Atoi(parts[3])
+	if err != nil || n <= 0 {
+		return PRRef{}, false
+	}
+	return PRRef{Owner: parts[0], Repo: parts[1], Number: n}, true
+}
+
+// PRState is the part of a pull request's state that maps to reactions.
+type PRState struct {
+	Merged bool
+	Closed bool
+	// Reviews holds the latest approving or change-requesting review per reviewer, oldest first.
+	Reviews []Review
+}
+
+type Review struct {
+	User  string
+	State string
+}
+
+// Actions returns the actions a webhook would have reported for the PR so far:
+// approved and/or changes_requested from the reviews, then merged or closed.
+func (s PRState) Actions() []Action {
+	var out []Action
+	seen := map[Action]bool{}
+	for _, r := range s.Reviews {
+		var a Action
+		switch r.State {
+		case "approved":
+			a = ActionApproved
+		case "changes_requested":
+			a = ActionChangesRequested
+		default:
+			continue
+		}
+		if !seen[a] {
+			seen[a] = true
+			out = append(out, a)
+		}
+	}
+	switch {
+	case s.Merged:
+		out = append(out, ActionMerged)
+	case s.Closed:
+		out = append(out, ActionClosed)
+	}
+	return out
+}
+
+// PullRequestState fetches the PR's merged/closed state and its reviews.
+func (c *Client) PullRequestState(ctx context.Context, pr PRRef) (st PRState, err error) {
+	ctx, span := tracing.Start(ctx, "github.PullRequestState",
+		attribute.String("github.repo", pr.Owner+"/"+pr.Repo),
+		attribute.Int("github.pr_number", pr.Number),
+	)
+	defer func() {
+		if err != nil {
+			tracing.Fail(span, err)
+		}
+		span.End()
+	}()
+
+	base := fmt.Sprintf("repos/%s/%s/pulls/%d", url.PathEscape(pr.Owner), url.PathEscape(pr.Repo), pr.Number)
+	var p struct {
+		State  string `json:"state"`
+		Merged bool   `json:"merged"`
+	}
+	if err := c.get(ctx, pr.Owner, base, nil, &p); err != nil {
+		return PRState{}, err
+	}
+	st.Merged = p.Merged
+	st.Closed = p.State == "closed"
+
+	// Only a reviewer's latest decision counts; a dismissal withdraws it.
+	latest := map[string]string{}
+	var order []string
+	for page := 1; ; page++ {
+		q := url.Values{}
+		q.Set("per_page", strconv.Itoa(reviewsPerPage))
+		q.Set("page", strconv.Itoa(page))
+		var reviews []struct {
+			State string `json:"state"`
+			User  struct {
+				Login string `json:"login"`
+			} `json:"user"`
+		}
+		if err := c.get(ctx, pr.Owner, base+"/reviews", q, &reviews); err != nil {
+			return PRState{}, err
+		}
+		for _, r := range reviews {
+			user, state := r.User.Login, strings.ToLower(r.State)
+			if state != "approved" && state != "changes_requested" && state != "dismissed" {
+				continue
+			}
+			order = slices.DeleteFunc(order, func(u string) bool { return u == user })
+			if state == "dismissed" {
+				delete(latest, user)
+				continue
+			}
+			latest[user] = state
+			order = append(order, user)
+		}
+		if len(reviews) < reviewsPerPage {
+			break
+		}
+	}
+	for _, user := range order {
+		st.Reviews = append(st.Reviews, Review{User: user, State: latest[user]})
+	}
+	return st, nil
+}
+
+// get GETs path for a repository of owner and decodes the response into out.
+func (c *Client) get(ctx context.Context, owner, path string, query url.Values, out any) error {
+	token, err := c.auth.token(ctx, owner)
+	if err != nil {
+		return fmt.Errorf("github auth: %w", err)
+	}
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	return c.do(ctx, http.MethodGet, path, token, out)
+}
+
+// do sends an API request authenticated with token (if any) and decodes a 2xx response into out.
+func (c *Client) do(ctx context.Context, method, path, token string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, nil)
+	if err != nil {
+		return fmt.Errorf("new request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return fmt.Errorf("github %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read github response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var e struct {
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal(b, &e)
+		return &APIError{Path: path, StatusCode: resp.StatusCode, Message: e.Message}
+	}
+	if err := json.Unmarshal(b, out); err != nil {
+		return fmt.Errorf("decode github %s response: %w", path, err)
+	}
+	return nil
+}
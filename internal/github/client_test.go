@@ -0,0 +1,68 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParsePRURL(t *testing.T) {
+	pr, ok := ParsePRURL("https://github.com/o/r/pull/123")
+	if !ok || pr != (PRRef{Owner: "o", Repo: "r", Number: 123}) {
+		t.Fatalf("unexpected ref: %+v %v", pr, ok)
+	}
+	for _, u := range []string{"https://github.com/o/r/issues/1", "https://github.com/o/r/pull/x", "https://github.com/o"} {
+		if _, ok := ParsePRURL(u); ok {
+			t.Fatalf("expected %s to be rejected", u)
+		}
+	}
+}
+
+func TestPullRequestState(t *testing.T) {
+	var reviews string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer ghp-test" {
+			t.Errorf("unexpected auth header: %q", r.Header.Get("Authorization"))
+		}
+		switch r.URL.Path {
+		case "/api/v3/repos/o/r/pulls/1":
+			_, _ = w.Write([]byte(`{"state":"closed","merged":true}`))
+		case "/api/v3/repos/o/r/pulls/1/reviews":
+			_, _ = w.Write([]byte(reviews))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message":"Not Found"}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	c := NewClient("ghp-test", srv.URL+"/api/v3")
+
+	t.Run("latest decision per reviewer", func(t *testing.T) {
+		reviews = `[
+			{"state":"CHANGES_REQUESTED","user":{"login":"alice"}},
+			{"state":"COMMENTED","user":{"login":"bob"}},
+			{"state":"APPROVED","user":{"login":"alice"}},
+			{"state":"CHANGES_REQUESTED","user":{"login":"carol"}},
+			{"state":"DISMISSED","user":{"login":"carol"}}
+		]`
+		st, err := c.PullRequestState(context.Background(), PRRef{Owner: "o", Repo: "r", Number: 1})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		want := []Action{ActionApproved, ActionMerged}
+		if got := st.Actions(); !reflect.DeepEqual(got, want) {
+			t.Fatalf("expected %v got %v", want, got)
+		}
+	})
+
+	t.Run("surfaces api errors", func(t *testing.T) {
+		_, err := c.PullRequestState(context.Background(), PRRef{Owner: "o", Repo: "r", Number: 2})
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+			t.Fatalf("expected 404 APIError got %v", err)
+		}
+	})
+}
@@ -1,6 +1,7 @@
 package github
 
 import (
+	"encoding/json"
 	"strings"
 )
 
@@ -14,10 +15,65 @@ const (
 	ActionClosed           Action = "closed"
 )
 
+// Actions lists every action prmoji reacts to.
+var Actions = []Action{ActionCommented, ActionApproved, ActionChangesRequested, ActionMerged, ActionClosed}
+
 type Classification struct {
 	Action    Action
 	PRURL     string
 	Commenter string
+	// Sender is the account that triggered the webhook and SenderType its kind ("User", "Bot").
+	Sender     string
+	SenderType string
+	// Author is the account that opened the PR.
+	Author string
+}
+
+// Actor is who the action is attributed to: the commenter or reviewer, else the webhook's sender.
+func (c Classification) Actor() string {
+	if c.Commenter != "" {
+		return c.Commenter
+	}
+	return c.Sender
+}
+
+// ActorIsBot reports whether the action was taken by a bot account, going by the sender's type
+// and the "[bot]" suffix GitHub gives app accounts.
+func (c Classification) ActorIsBot() bool {
+	if strings.HasSuffix(strings.ToLower(c.Actor()), "[bot]") {
+		return true
+	}
+	return strings.EqualFold(c.SenderType, "bot") && strings.EqualFold(c.Actor(), c.Sender)
+}
+
+type sender struct {
+	Login string `json:"login"`
+	Type  string `json:"type"`
+}
+
+// EventLabel normalizes an X-GitHub-Event header for use as a metric label, folding
+// unsupported event types into "other" to keep label cardinality bounded.
+func EventLabel(eventType string) string {
+	switch e := strings.ToLower(strings.TrimSpace(eventType)); e {
+	case "issue_comment", "pull_request_review", "pull_request", "ping":
+		return e
+	default:
+		return "other"
+	}
+}
+
+// Repository returns the "owner/repo" a webhook payload of any event type is about, or ""
+// for events that aren't about a repository.
+func Repository(body []byte) string {
+	var e struct {
+		Repository struct {
+			FullName string `json:"full_name"`
+		} `json:"repository"`
+	}
+	if err := json.Unmarshal(body, &e); err != nil {
+		return ""
+	}
+	return e.Repository.FullName
 }
 
 func Classify(eventType string, body []byte) (Classification, bool) {
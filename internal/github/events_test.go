@@ -35,6 +35,18 @@ func TestClassify_PullRequestMerged(t *testing.T) {
 	if c.Action != ActionMerged {
 		t.Fatalf("expected %q got %q", ActionMerged, c.Action)
 	}
+	if c.Actor() != "mergify[bot]" || !c.ActorIsBot() || c.Author != "carol" {
+		t.Fatalf("unexpected actor %q (bot %v), author %q", c.Actor(), c.ActorIsBot(), c.Author)
+	}
+}
+
+func TestRepository(t *testing.T) {
+	if got := Repository([]byte(`{"zen":"hi","repository":{"full_name":"acme/web"}}`)); got != "acme/web" {
+		t.Fatalf("expected acme/web got %q", got)
+	}
+	if got := Repository(fixturePullRequestMerged); got != "" {
+		t.Fatalf("expected no repository got %q", got)
+	}
 }
 
 func TestClassify_IssueCommentCreated(t *testing.T) {
@@ -48,4 +60,7 @@ func TestClassify_IssueCommentCreated(t *testing.T) {
 	if c.Commenter != "bob" {
 		t.Fatalf("expected commenter bob got %q", c.Commenter)
 	}
+	if c.Author != "carol" || c.ActorIsBot() {
+		t.Fatalf("unexpected author %q or bot %v", c.Author, c.ActorIsBot())
+	}
 }
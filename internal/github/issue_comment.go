@@ -5,6 +5,9 @@ import "encoding/json"
 type issueCommentEvent struct {
 	Action string `json:"action"`
 	Issue  struct {
+		User struct {
+			Login string `json:"login"`
+		} `json:"user"`
 		PullRequest struct {
 			HTMLURL string `json:"html_url"`
 		} `json:"pull_request"`
@@ -14,6 +17,7 @@ type issueCommentEvent struct {
 			Login string `json:"login"`
 		} `json:"user"`
 	} `json:"comment"`
+	Sender sender `json:"sender"`
 }
 
 func classifyIssueComment(body []byte) (Classification, bool) {
@@ -28,8 +32,11 @@ func classifyIssueComment(body []byte) (Classification, bool) {
 		return Classification{}, false
 	}
 	return Classification{
-		Action:    ActionCommented,
-		PRURL:     e.Issue.PullRequest.HTMLURL,
-		Commenter: e.Comment.User.Login,
+		Action:     ActionCommented,
+		PRURL:      e.Issue.PullRequest.HTMLURL,
+		Commenter:  e.Comment.User.Login,
+		Sender:     e.Sender.Login,
+		SenderType: e.Sender.Type,
+		Author:     e.Issue.User.Login,
 	}, true
 }
@@ -7,7 +7,11 @@ type pullRequestEvent struct {
 	PullRequest struct {
 		Merged  bool   `json:"merged"`
 		HTMLURL string `json:"html_url"`
+		User    struct {
+			Login string `json:"login"`
+		} `json:"user"`
 	} `json:"pull_request"`
+	Sender sender `json:"sender"`
 }
 
 func classifyPullRequest(body []byte) (Classification, bool) {
@@ -21,8 +25,15 @@ func classifyPullRequest(body []byte) (Classification, bool) {
 	if e.PullRequest.HTMLURL == "" {
 		return Classification{}, false
 	}
+	c := Classification{
+		Action:     ActionClosed,
+		PRURL:      e.PullRequest.HTMLURL,
+		Sender:     e.Sender.Login,
+		SenderType: e.Sender.Type,
+		Author:     e.PullRequest.User.Login,
+	}
 	if e.PullRequest.Merged {
-		return Classification{Action: ActionMerged, PRURL: e.PullRequest.HTMLURL}, true
+		c.Action = ActionMerged
 	}
-	return Classification{Action: ActionClosed, PRURL: e.PullRequest.HTMLURL}, true
+	return c, true
 }
@@ -15,7 +15,11 @@ type prReviewEvent struct {
 	} `json:"review"`
 	PullRequest struct {
 		HTMLURL string `json:"html_url"`
+		User    struct {
+			Login string `json:"login"`
+		} `json:"user"`
 	} `json:"pull_request"`
+	Sender sender `json:"sender"`
 }
 
 func classifyPRReview(body []byte) (Classification, bool) {
@@ -30,14 +34,22 @@ func classifyPRReview(body []byte) (Classification, bool) {
 		return Classification{}, false
 	}
 
+	c := Classification{
+		PRURL:      e.PullRequest.HTMLURL,
+		Commenter:  e.Review.User.Login,
+		Sender:     e.Sender.Login,
+		SenderType: e.Sender.Type,
+		Author:     e.PullRequest.User.Login,
+	}
 	switch strings.ToLower(e.Review.State) {
 	case "commented":
-		return Classification{Action: ActionCommented, PRURL: e.PullRequest.HTMLURL, Commenter: e.Review.User.Login}, true
+		c.Action = ActionCommented
 	case "approved":
-		return Classification{Action: ActionApproved, PRURL: e.PullRequest.HTMLURL, Commenter: e.Review.User.Login}, true
+		c.Action = ActionApproved
 	case "changes_requested":
-		return Classification{Action: ActionChangesRequested, PRURL: e.PullRequest.HTMLURL, Commenter: e.Review.User.Login}, true
+		c.Action = ActionChangesRequested
 	default:
 		return Classification{}, false
 	}
+	return c, true
 }
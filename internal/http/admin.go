@@ -0,0 +1,274 @@
+package http
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adamantal/prmoji/internal/slack"
+	"github.com/adamantal/prmoji/internal/store"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 500
+
+	// adminWriteTimeout replaces the server's write timeout for admin requests, which may call
+	// Slack many times. Admin handler budgets must stay below it so the report still gets out.
+	adminWriteTimeout = 90 * time.Second
+)
+
+func (h *Handlers) registerAdmin(mux *http.ServeMux) {
+	mux.HandleFunc("GET /admin/prs", h.requireAdmin(h.handleAdminListPRs))
+	mux.HandleFunc("GET /admin/pr", h.requireAdmin(h.handleAdminGetPR))
+	mux.HandleFunc("DELETE /admin/pr", h.requireAdmin(h.handleAdminUntrackPR))
+	mux.HandleFunc("POST /admin/pr/reapply", h.requireAdmin(h.handleAdminReapply))
+	mux.HandleFunc("POST /admin/messages", h.requireAdmin(h.handleAdminTrackMessage))
+	mux.HandleFunc("DELETE /admin/messages/{id}", h.requireAdmin(h.handleAdminUntrackMessage))
+	mux.HandleFunc("GET /admin/failed-reactions", h.requireAdmin(h.handleAdminListFailedReactions))
+	mux.HandleFunc("POST /admin/failed-reactions/retry", h.requireAdmin(h.handleAdminRetryFailedReactions))
+	mux.HandleFunc("POST /admin/failed-reactions/{id}/retry", h.requireAdmin(h.handleAdminRetryFailedReaction))
+	mux.HandleFunc("DELETE /admin/failed-reactions/{id}", h.requireAdmin(h.handleAdminDeleteFailedReaction))
+	mux.HandleFunc("GET /admin/deliveries", h.requireAdmin(h.handleAdminListDeliveries))
+	mux.HandleFunc("GET /admin/deliveries/{id}", h.requireAdmin(h.handleAdminGetDelivery))
+	mux.HandleFunc("POST /admin/deliveries/{id}/replay", h.requireAdmin(h.handleAdminReplayDelivery))
+}
+
+// requireAdmin rejects requests that don't carry the configured admin token as a bearer token.
+func (h *Handlers) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.Cfg.AdminToken)) != 1 {
+			h.Log.WarnContext(r.Context(), "rejected admin request", "method", r.Method, "path", r.URL.Path)
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(adminWriteTimeout)); err != nil {
+			h.Log.WarnContext(r.Context(), "extend admin write deadline failed", "err", err, "path", r.URL.Path)
+		}
+		next(w, r)
+	}
+}
+
+type page struct {
+	Items  any   `json:"items"`
+	Total  int64 `json:"total"`
+	Limit  int   `json:"limit"`
+	Offset int   `json:"offset"`
+}
+
+type trackedPRView struct {
+	store.TrackedPR
+	Messages []store.Message `json:"messages"`
+}
+
+type prView struct {
+	PRURL     string          `json:"pr_url"`
+	Messages  []store.Message `json:"messages"`
+	Reactions []string        `json:"reactions"`
+}
+
+func (h *Handlers) handleAdminListPRs(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	ctx := r.Context()
+
+	total, err := h.Store.CountTrackedPRs(ctx)
+	if err != nil {
+		h.adminError(w, r, "count tracked prs failed", err)
+		return
+	}
+	prs, err := h.Store.ListTrackedPRs(ctx, limit, offset)
+	if err != nil {
+		h.adminError(w, r, "list tracked prs failed", err)
+		return
+	}
+
+	items := make([]trackedPRView, 0, len(prs))
+	for _, pr := range prs {
+		msgs, err := h.Store.ListMessagesByPRURL(ctx, pr.PRURL)
+		if err != nil {
+			h.adminError(w, r, "list messages failed", err)
+			return
+		}
+		items = append(items, trackedPRView{TrackedPR: pr, Messages: nonNil(msgs)})
+	}
+	writeJSON(w, http.StatusOK, page{Items: items, Total: total, Limit: limit, Offset: offset})
+}
+
+func (h *Handlers) handleAdminGetPR(w http.ResponseWriter, r *http.Request) {
+	prURL, ok := prURLParam(w, r)
+	if !ok {
+		return
+	}
+	msgs, err := h.Store.ListMessagesByPRURL(r.Context(), prURL)
+	if err != nil {
+		h.adminError(w, r, "list messages failed", err)
+		return
+	}
+	if len(msgs) == 0 {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "pr not tracked"})
+		return
+	}
+	reactions, err := h.Store.ListPRReactions(r.Context(), prURL)
+	if err != nil {
+		h.adminError(w, r, "list reactions failed", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, prView{PRURL: prURL, Messages: msgs, Reactions: nonNil(reactions)})
+}
+
+func (h *Handlers) handleAdminUntrackPR(w http.ResponseWriter, r *http.Request) {
+	prURL, ok := prURLParam(w, r)
+	if !ok {
+		return
+	}
+	if err := h.Store.DeleteByPRURL(r.Context(), prURL); err != nil {
+		h.adminError(w, r, "untrack pr failed", err)
+		return
+	}
+	h.Log.InfoContext(r.Context(), "admin untracked pr", "pr_url", prURL)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type trackMessageRequest struct {
+	PRURL string `json:"pr_url"`
+	// TeamID is the Slack workspace of the message; empty uses SLACK_TOKEN.
+	TeamID  string `json:"team_id,omitempty"`
+	Channel string `json:"channel"`
+	TS      string `json:"ts"`
+}
+
+func (h *Handlers) handleAdminTrackMessage(w http.ResponseWriter, r *http.Request) {
+	body, err := readBody(r, 1<<20)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "read body failed"})
+		return
+	}
+	var req trackMessageRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+		return
+	}
+	if !slack.IsPRURL(req.PRURL) || strings.TrimSpace(req.Channel) == "" || strings.TrimSpace(req.TS) == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "pr_url, channel and ts are required"})
+		return
+	}
+	if err := h.Store.InsertPRMessage(r.Context(), req.PRURL, req.TeamID, req.Channel, req.TS); err != nil {
+		h.adminError(w, r, "track message failed", err)
+		return
+	}
+	h.Log.InfoContext(r.Context(), "admin tracked message", "pr_url", req.PRURL, "channel", req.Channel, "ts", req.TS)
+	writeJSON(w, http.StatusCreated, req)
+}
+
+func (h *Handlers) handleAdminUntrackMessage(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	found, err := h.Store.DeleteMessage(r.Context(), id)
+	if err != nil {
+		h.adminError(w, r, "untrack message failed", err)
+		return
+	}
+	if !found {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "message not found"})
+		return
+	}
+	h.Log.InfoContext(r.Context(), "admin untracked message", "id", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type reapplyResult struct {
+	PRURL     string   `json:"pr_url"`
+	Messages  int      `json:"messages"`
+	Reactions []string `json:"reactions"`
+	Failed    int      `json:"failed"`
+}
+
+func (h *Handlers) handleAdminReapply(w http.ResponseWriter, r *http.Request) {
+	prURL, ok := prURLParam(w, r)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 20*time.Second)
+	defer cancel()
+
+	msgs, err := h.Store.ListMessagesByPRURL(ctx, prURL)
+	if err != nil {
+		h.adminError(w, r, "list messages failed", err)
+		return
+	}
+	if len(msgs) == 0 {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "pr not tracked"})
+		return
+	}
+	reactions, err := h.Store.ListPRReactions(ctx, prURL)
+	if err != nil {
+		h.adminError(w, r, "list reactions failed", err)
+		return
+	}
+
+	res := reapplyResult{PRURL: prURL, Messages: len(msgs), Reactions: nonNil(reactions)}
+	// Like webhook reactions, ones Slack rejects are recorded for the retry loop.
+	for _, m := range msgs {
+		for _, emoji := range reactions {
+			if rr := h.react(ctx, prURL, m.TeamID, m.MessageChannel, m.MessageTimestamp, emoji); rr.Error != "" {
+				res.Failed++
+			}
+		}
+	}
+	h.Log.InfoContext(ctx, "admin reapplied reactions", "pr_url", prURL, "messages", res.Messages, "reactions", len(reactions), "failed", res.Failed)
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (h *Handlers) adminError(w http.ResponseWriter, r *http.Request, msg string, err error) {
+	h.Log.ErrorContext(r.Context(), msg, "err", err)
+	writeJSON(w, http.StatusInternalServerError, map[string]string{"error": msg})
+}
+
+func parsePagination(r *http.Request) (limit, offset int, err error) {
+	q := r.URL.Query()
+	limit = defaultPageLimit
+	if raw := q.Get("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit <= 0 || limit > maxPageLimit {
+			return 0, 0, fmt.Errorf("limit must be between 1 and %d", maxPageLimit)
+		}
+	}
+	if raw := q.Get("offset"); raw != "" {
+		offset, err = strconv.Atoi(raw)
+		if err != nil || offset < 0 {
+			return 0, 0, errors.New("offset must be a non-negative integer")
+		}
+	}
+	return limit, offset, nil
+}
+
+func prURLParam(w http.ResponseWriter, r *http.Request) (string, bool) {
+	prURL := strings.TrimSpace(r.URL.Query().Get("pr_url"))
+	if !slack.IsPRURL(prURL) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "pr_url must be a GitHub pull request URL"})
+		return "", false
+	}
+	return prURL, true
+}
+
+// nonNil keeps empty lists as [] rather than null in JSON responses.
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
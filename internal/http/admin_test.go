@@ -0,0 +1,198 @@
+package http
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adamantal/prmoji/internal/config"
+	"github.com/adamantal/prmoji/internal/store"
+)
+
+func newAdminServer(t *testing.T) (*httptest.Server, *store.SQLiteStore) {
+	t.Helper()
+	h := newTestHandlers(t, config.Config{AdminToken: "secret"})
+	st := h.Store
+	mux := http.NewServeMux()
+	h.Register(mux)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv, st
+}
+
+func adminRequest(t *testing.T, method, url, token, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("do request: %v", err)
+	}
+	t.Cleanup(func() { _ = resp.Body.Close() })
+	return resp
+}
+
+func TestAdminRequiresToken(t *testing.T) {
+	srv, _ := newAdminServer(t)
+
+	if resp := adminRequest(t, "GET", srv.URL+"/admin/prs", "", ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without token got %d", resp.StatusCode)
+	}
+	if resp := adminRequest(t, "GET", srv.URL+"/admin/prs", "wrong", ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 with wrong token got %d", resp.StatusCode)
+	}
+}
+
+func TestAdminTrackListUntrack(t *testing.T) {
+	srv, _ := newAdminServer(t)
+
+	for _, ts := range []string{"1.0", "2.0"} {
+		body := `{"pr_url":"https://github.com/o/r/pull/1","channel":"C1","ts":"` + ts + `"}`
+		if resp := adminRequest(t, "POST", srv.URL+"/admin/messages", "secret", body); resp.StatusCode != http.StatusCreated {
+			t.Fatalf("expected 201 got %d", resp.StatusCode)
+		}
+	}
+	if resp := adminRequest(t, "POST", srv.URL+"/admin/messages", "secret", `{"pr_url":"https://example.com"}`); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid message got %d", resp.StatusCode)
+	}
+
+	resp := adminRequest(t, "GET", srv.URL+"/admin/prs?limit=10", "secret", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 got %d", resp.StatusCode)
+	}
+	var got struct {
+		Total int64 `json:"total"`
+		Items []struct {
+			PRURL    string          `json:"pr_url"`
+			Messages []store.Message `json:"messages"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.Total != 1 || len(got.Items) != 1 || len(got.Items[0].Messages) != 2 {
+		t.Fatalf("unexpected listing: %#v", got)
+	}
+
+	id := got.Items[0].Messages[0].ID
+	if resp := adminRequest(t, "DELETE", srv.URL+"/admin/messages/"+itoa(id), "secret", ""); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected 204 got %d", resp.StatusCode)
+	}
+	if resp := adminRequest(t, "DELETE", srv.URL+"/admin/messages/"+itoa(id), "secret", ""); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for deleted message got %d", resp.StatusCode)
+	}
+
+	if resp := adminRequest(t, "DELETE", srv.URL+"/admin/pr?pr_url=https://github.com/o/r/pull/1", "secret", ""); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected 204 got %d", resp.StatusCode)
+	}
+	if resp := adminRequest(t, "GET", srv.URL+"/admin/pr?pr_url=https://github.com/o/r/pull/1", "secret", ""); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 after untrack got %d", resp.StatusCode)
+	}
+}
+
+func itoa(n int64) string {
+	return strconv.FormatInt(n, 10)
+}
+
+func TestAdminReplayDeliveryDryRun(t *testing.T) {
+	srv, st := newAdminServer(t)
+	ctx := t.Context()
+
+	prURL := "https://github.com/o/r/pull/1"
+	if err := st.InsertPRMessage(ctx, prURL, "T1", "C1", "1.0"); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	body := `{"action":"closed","pull_request":{"html_url":"` + prURL + `","merged":true}}`
+	id, err := st.InsertDelivery(ctx, store.Delivery{Source: sourceGitHub, DeliveryID: "d1", EventType: "pull_request", Body: []byte(body)})
+	if err != nil {
+		t.Fatalf("insert delivery: %v", err)
+	}
+
+	resp := adminRequest(t, "POST", srv.URL+"/admin/deliveries/"+strconv.FormatInt(id, 10)+"/replay?dry_run=true", "secret", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 got %d", resp.StatusCode)
+	}
+	var got ReplayResult
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !got.Result.DryRun || got.Result.Action != "merged" || len(got.Result.Reactions) != 1 {
+		t.Fatalf("unexpected result: %+v", got.Result)
+	}
+	msgs, err := st.ListMessagesByPRURL(ctx, prURL)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(msgs) != 1 {
+		t.Fatalf("dry run must not untrack the PR, got %d messages", len(msgs))
+	}
+
+	if resp := adminRequest(t, "POST", srv.URL+"/admin/deliveries/999/replay", "secret", ""); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 got %d", resp.StatusCode)
+	}
+}
+
+func TestAdminOutlivesServerWriteTimeout(t *testing.T) {
+	h := &Handlers{
+		Cfg: config.Config{AdminToken: "secret"},
+		Log: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	slow := h.requireAdmin(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		writeJSON(w, http.StatusOK, map[string]string{"status": "done"})
+	})
+	srv := httptest.NewUnstartedServer(slow)
+	srv.Config.WriteTimeout = 50 * time.Millisecond
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	resp := adminRequest(t, http.MethodPost, srv.URL, "secret", "")
+	body, err := io.ReadAll(resp.Body)
+	if err != nil || resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "done") {
+		t.Fatalf("expected the report after the server write timeout, got %d %q (%v)", resp.StatusCode, body, err)
+	}
+}
+
+func TestAdminReapply(t *testing.T) {
+	h := newTestHandlers(t, config.Config{AdminToken: "secret"})
+	fake := newFakeSlack(t, h)
+	mux := http.NewServeMux()
+	h.Register(mux)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	ctx := t.Context()
+
+	const prURL = "https://github.com/o/r/pull/1"
+	for _, channel := range []string{"C1", "C0FAIL"} {
+		if err := h.Store.InsertPRMessage(ctx, prURL, "", channel, "1.0"); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+	if err := h.Store.InsertPRReaction(ctx, prURL, "eyes"); err != nil {
+		t.Fatalf("insert reaction: %v", err)
+	}
+
+	resp := adminRequest(t, "POST", srv.URL+"/admin/pr/reapply?pr_url="+prURL, "secret", "")
+	var res reapplyResult
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("reapply: %d %v", resp.StatusCode, err)
+	}
+	if res.Messages != 2 || res.Failed != 1 || len(fake.Calls()) != 2 {
+		t.Fatalf("unexpected reapply result %+v, slack calls %v", res, fake.Calls())
+	}
+	failed, err := h.Store.ListFailedReactions(ctx, 10, 0)
+	if err != nil || len(failed) != 1 || failed[0].Channel != "C0FAIL" || failed[0].Emoji != "eyes" {
+		t.Fatalf("expected the rejected reaction to be queued for retry, got %+v (%v)", failed, err)
+	}
+}
@@ -0,0 +1,143 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/adamantal/prmoji/internal/log"
+	"github.com/adamantal/prmoji/internal/store"
+)
+
+const (
+	sourceSlack  = "slack"
+	sourceGitHub = "github"
+)
+
+// redactedHeaders are never written to the payload archive.
+var redactedHeaders = []string{"Authorization", "Cookie"}
+
+// archive stores a raw webhook payload when ARCHIVE_DAYS is set. Failures are logged only:
+// the archive is a debugging aid and must not affect processing.
+func (h *Handlers) archive(ctx context.Context, source, deliveryID, eventType string, header http.Header, body []byte) {
+	if h.settings().ArchiveDays <= 0 {
+		return
+	}
+	header = header.Clone()
+	for _, k := range redactedHeaders {
+		header.Del(k)
+	}
+	d := store.Delivery{
+		Source:     source,
+		DeliveryID: deliveryID,
+		EventType:  eventType,
+		Headers:    header,
+		Body:       body,
+	}
+	if _, err := h.Store.InsertDelivery(ctx, d); err != nil {
+		h.Log.ErrorContext(ctx, "archive delivery failed", "err", err, "source", source, "delivery_id", deliveryID)
+	}
+}
+
+type ReplayResult struct {
+	Delivery store.Delivery `json:"delivery"`
+	Result   Result         `json:"result"`
+}
+
+// Replay runs an archived delivery through the same processing path as a live webhook.
+// In dry-run mode nothing is written to the store or sent to Slack.
+func (h *Handlers) Replay(ctx context.Context, id int64, dryRun bool) (ReplayResult, error) {
+	d, err := h.Store.GetDelivery(ctx, id)
+	if err != nil {
+		return ReplayResult{}, err
+	}
+	ctx = log.WithRequestID(ctx, "replay-"+strconv.FormatInt(id, 10))
+	if dryRun {
+		ctx = withDryRun(ctx)
+	}
+	h.Log.InfoContext(ctx, "replaying delivery", "id", id, "source", d.Source, "delivery_id", d.DeliveryID, "event", d.EventType, "dry_run", dryRun)
+
+	res := ReplayResult{Delivery: d}
+	switch d.Source {
+	case sourceGitHub:
+		res.Result = h.processGitHubEvent(ctx, d.EventType, d.Body)
+	case sourceSlack:
+		res.Result = h.processSlackEvent(ctx, d.Body)
+	default:
+		return ReplayResult{}, fmt.Errorf("unknown delivery source: %q", d.Source)
+	}
+	return res, nil
+}
+
+type deliveryView struct {
+	store.Delivery
+	Body string `json:"body"`
+}
+
+func (h *Handlers) handleAdminListDeliveries(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	source := strings.TrimSpace(r.URL.Query().Get("source"))
+
+	total, err := h.Store.CountDeliveries(r.Context(), source)
+	if err != nil {
+		h.adminError(w, r, "count deliveries failed", err)
+		return
+	}
+	items, err := h.Store.ListDeliveries(r.Context(), source, limit, offset)
+	if err != nil {
+		h.adminError(w, r, "list deliveries failed", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, page{Items: nonNil(items), Total: total, Limit: limit, Offset: offset})
+}
+
+func (h *Handlers) handleAdminGetDelivery(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	d, err := h.Store.GetDelivery(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "delivery not found"})
+		return
+	}
+	if err != nil {
+		h.adminError(w, r, "get delivery failed", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, deliveryView{Delivery: d, Body: string(d.Body)})
+}
+
+func (h *Handlers) handleAdminReplayDelivery(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	dryRun := false
+	if raw := strings.TrimSpace(r.URL.Query().Get("dry_run")); raw != "" {
+		if dryRun, err = strconv.ParseBool(raw); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid dry_run: %q", raw)})
+			return
+		}
+	}
+
+	res, err := h.Replay(r.Context(), id, dryRun)
+	if errors.Is(err, store.ErrNotFound) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "delivery not found"})
+		return
+	}
+	if err != nil {
+		h.adminError(w, r, "replay delivery failed", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
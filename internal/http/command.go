@@ -0,0 +1,219 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/adamantal/prmoji/internal/github"
+	"github.com/adamantal/prmoji/internal/slack"
+	"github.com/adamantal/prmoji/internal/store"
+)
+
+// maxListedPRs caps the PRs shown by the list subcommand; Slack truncates long responses anyway.
+const maxListedPRs = 20
+
+const commandHelp = "Usage:\n" +
+	"• `status <PR URL>`: messages tracked for a PR, the reactions applied so far and its state on GitHub\n" +
+	"• `untrack <PR URL>`: stop reacting to a PR\n" +
+	"• `list`: PRs tracked in this channel\n" +
+	"• `help`: this message"
+
+func (h *Handlers) handleSlackCommand(w http.ResponseWriter, r *http.Request) {
+	body, err := readBody(r, 1<<20)
+	if err != nil {
+		h.Log.WarnContext(r.Context(), "read slack command body failed", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := slack.VerifySignature(h.Cfg.SlackSigningSecret, r.Header, body, time.Now()); err != nil {
+		h.Log.WarnContext(r.Context(), "rejected slack command", "err", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	cmd, err := slack.ParseSlashCommand(body)
+	if err != nil {
+		h.Log.WarnContext(r.Context(), "parse slack command failed", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// Slack gives up on slash commands after 3 seconds.
+	ctx, cancel := context.WithTimeout(r.Context(), 2500*time.Millisecond)
+	defer cancel()
+
+	text := h.runSlackCommand(ctx, cmd)
+	writeJSON(w, http.StatusOK, slack.CommandResponse{ResponseType: slack.ResponseEphemeral, Text: text})
+}
+
+// runSlackCommand executes a /prmoji invocation and returns the reply text.
+func (h *Handlers) runSlackCommand(ctx context.Context, cmd slack.SlashCommand) string {
+	fields := strings.Fields(cmd.Text)
+	sub := "help"
+	if len(fields) > 0 {
+		sub = strings.ToLower(fields[0])
+	}
+	h.Log.InfoContext(ctx, "slack command", "command", cmd.Command, "sub", sub, "user_id", cmd.UserID, "channel", cmd.ChannelID)
+
+	switch sub {
+	case "status":
+		prURL, ok := commandPRURL(fields)
+		if !ok {
+			return "Usage: `status <PR URL>`"
+		}
+		return h.commandStatus(ctx, prURL, cmd.TeamID)
+	case "untrack":
+		prURL, ok := commandPRURL(fields)
+		if !ok {
+			return "Usage: `untrack <PR URL>`"
+		}
+		return h.commandUntrack(ctx, prURL, cmd.TeamID, cmd.UserID)
+	case "list":
+		return h.commandList(ctx, cmd.ChannelID)
+	case "help":
+		return commandHelp
+	default:
+		return fmt.Sprintf("Unknown command `%s`.\n%s", sub, commandHelp)
+	}
+}
+
+// commandPRURL extracts the PR URL argument; Slack wraps links in <...>, which ExtractPRURLs ignores.
+func commandPRURL(fields []string) (string, bool) {
+	if len(fields) != 2 {
+		return "", false
+	}
+	urls := slack.ExtractPRURLs(fields[1])
+	if len(urls) != 1 {
+		return "", false
+	}
+	return urls[0], true
+}
+
+// commandStatus and commandUntrack only see the messages of the workspace the command came from.
+func (h *Handlers) commandStatus(ctx context.Context, prURL, teamID string) string {
+	all, err := h.Store.ListMessagesByPRURL(ctx, prURL)
+	if err != nil {
+		h.Log.ErrorContext(ctx, "list messages failed", "err", err, "pr_url", prURL)
+		return "Something went wrong, please try again."
+	}
+	var msgs []store.Message
+	for _, m := range all {
+		if m.TeamID == teamID {
+			msgs = append(msgs, m)
+		}
+	}
+	if len(msgs) == 0 {
+		return fmt.Sprintf("%s is not tracked.", prURL)
+	}
+	reactions, err := h.Store.ListPRReactions(ctx, prURL)
+	if err != nil {
+		h.Log.ErrorContext(ctx, "list reactions failed", "err", err, "pr_url", prURL)
+		return "Something went wrong, please try again."
+	}
+
+	channels := make([]string, 0, len(msgs))
+	seen := map[string]bool{}
+	for _, m := range msgs {
+		if !seen[m.MessageChannel] {
+			seen[m.MessageChannel] = true
+			channels = append(channels, "<#"+m.MessageChannel+">")
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s is tracked in %d message(s) in %s.\n", prURL, len(msgs), strings.Join(channels, ", "))
+	if len(reactions) == 0 {
+		b.WriteString("No reactions yet.")
+	} else {
+		b.WriteString("Reactions so far:")
+		for _, e := range reactions {
+			b.WriteString(" :" + e + ":")
+		}
+	}
+	at, err := h.Store.GetPRActivity(ctx, prURL)
+	switch {
+	case err == nil:
+		fmt.Fprintf(&b, "\nLast GitHub activity: %s", at.UTC().Format("2006-01-02 15:04 MST"))
+	case !errors.Is(err, store.ErrNotFound):
+		h.Log.ErrorContext(ctx, "get pr activity failed", "err", err, "pr_url", prURL)
+	}
+	if h.GitHub != nil {
+		b.WriteString("\n" + h.livePRState(ctx, prURL))
+	}
+	return b.String()
+}
+
+// livePRState describes the PR as GitHub reports it now. Unlike applyPRState it reacts to nothing.
+func (h *Handlers) livePRState(ctx context.Context, prURL string) string {
+	const prefix = "On GitHub now: "
+	pr, ok := github.ParsePRURL(prURL)
+	if !ok {
+		return prefix + "unknown"
+	}
+	state, err := h.GitHub.PullRequestState(ctx, pr)
+	if err != nil {
+		h.Log.WarnContext(ctx, "fetch pr state failed", "err", err, "pr_url", prURL)
+		return prefix + "could not be fetched"
+	}
+
+	parts := []string{"open"}
+	switch {
+	case state.Merged:
+		parts[0] = "merged"
+	case state.Closed:
+		parts[0] = "closed"
+	}
+	var approved, changes []string
+	for _, r := range state.Reviews {
+		if r.State == "approved" {
+			approved = append(approved, r.User)
+		} else {
+			changes = append(changes, r.User)
+		}
+	}
+	if len(approved) > 0 {
+		parts = append(parts, "approved by "+strings.Join(approved, ", "))
+	}
+	if len(changes) > 0 {
+		parts = append(parts, "changes requested by "+strings.Join(changes, ", "))
+	}
+	return prefix + strings.Join(parts, "; ")
+}
+
+func (h *Handlers) commandUntrack(ctx context.Context, prURL, teamID, userID string) string {
+	n, err := h.Store.DeleteByPRURLInTeam(ctx, prURL, teamID)
+	if err != nil {
+		h.Log.ErrorContext(ctx, "untrack pr failed", "err", err, "pr_url", prURL, "team_id", teamID)
+		return "Something went wrong, please try again."
+	}
+	if n == 0 {
+		return fmt.Sprintf("%s is not tracked.", prURL)
+	}
+	h.Log.InfoContext(ctx, "slack command untracked pr", "pr_url", prURL, "team_id", teamID, "user_id", userID, "messages", n)
+	return fmt.Sprintf("Stopped tracking %s (%d message(s)).", prURL, n)
+}
+
+func (h *Handlers) commandList(ctx context.Context, channel string) string {
+	prs, err := h.Store.ListTrackedPRsInChannel(ctx, channel, maxListedPRs+1, 0)
+	if err != nil {
+		h.Log.ErrorContext(ctx, "list tracked prs failed", "err", err, "channel", channel)
+		return "Something went wrong, please try again."
+	}
+	if len(prs) == 0 {
+		return "No PRs are tracked in this channel."
+	}
+
+	var b strings.Builder
+	b.WriteString("PRs tracked in this channel, most recently posted first:")
+	for i, pr := range prs {
+		if i == maxListedPRs {
+			b.WriteString("\n…and more")
+			break
+		}
+		fmt.Fprintf(&b, "\n• %s", pr.PRURL)
+	}
+	return b.String()
+}
@@ -0,0 +1,171 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adamantal/prmoji/internal/config"
+	"github.com/adamantal/prmoji/internal/github"
+	"github.com/adamantal/prmoji/internal/slack"
+)
+
+func slackCommand(t *testing.T, srv *httptest.Server, secret, channel, text string) (int, slack.CommandResponse) {
+	t.Helper()
+	return slackCommandInTeam(t, srv, secret, "T1", channel, text)
+}
+
+func slackCommandInTeam(t *testing.T, srv *httptest.Server, secret, team, channel, text string) (int, slack.CommandResponse) {
+	t.Helper()
+	body := url.Values{"command": {"/prmoji"}, "team_id": {team}, "channel_id": {channel}, "user_id": {"U1"}, "text": {text}}.Encode()
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req, err := http.NewRequest("POST", srv.URL+"/command/slack", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Slack-Request-Timestamp", ts)
+	req.Header.Set("X-Slack-Signature", slack.Signature(secret, ts, []byte(body)))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("do request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var out slack.CommandResponse
+	if resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+	}
+	return resp.StatusCode, out
+}
+
+func TestSlackCommand(t *testing.T) {
+
+	h := newTestHandlers(t, config.Config{SlackSigningSecret: "secret"})
+	st := h.Store
+	mux := http.NewServeMux()
+	h.Register(mux)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	const prURL = "https://github.com/o/r/pull/1"
+	if err := st.InsertPRMessage(t.Context(), prURL, "T1", "C1", "1.0"); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	if code, _ := slackCommand(t, srv, "wrong", "C1", "list"); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a bad signature got %d", code)
+	}
+
+	_, resp := slackCommand(t, srv, "secret", "C1", "list")
+	if resp.ResponseType != slack.ResponseEphemeral || !strings.Contains(resp.Text, prURL) {
+		t.Fatalf("unexpected list response: %+v", resp)
+	}
+	if _, resp := slackCommand(t, srv, "secret", "C2", "list"); strings.Contains(resp.Text, prURL) {
+		t.Fatalf("list must only show the current channel: %+v", resp)
+	}
+
+	_, resp = slackCommand(t, srv, "secret", "C1", "status <"+prURL+">")
+	if !strings.Contains(resp.Text, "tracked in 1 message(s)") {
+		t.Fatalf("unexpected status response: %+v", resp)
+	}
+
+	_, resp = slackCommand(t, srv, "secret", "C1", "untrack "+prURL)
+	if !strings.HasPrefix(resp.Text, "Stopped tracking") {
+		t.Fatalf("unexpected untrack response: %+v", resp)
+	}
+	_, resp = slackCommand(t, srv, "secret", "C1", "status "+prURL)
+	if !strings.Contains(resp.Text, "is not tracked") {
+		t.Fatalf("expected PR to be untracked: %+v", resp)
+	}
+}
+
+func TestSlackCommandStatusLivePRState(t *testing.T) {
+	gh := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/o/r/pulls/1":
+			_, _ = w.Write([]byte(`{"state":"open","merged":false}`))
+		case "/repos/o/r/pulls/1/reviews":
+			_, _ = w.Write([]byte(`[{"state":"APPROVED","user":{"login":"alice"}},{"state":"CHANGES_REQUESTED","user":{"login":"bob"}}]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(gh.Close)
+
+	h := newTestHandlers(t, config.Config{})
+	h.GitHub = github.NewClient("ghp-test", gh.URL)
+	st := h.Store
+
+	for _, prURL := range []string{"https://github.com/o/r/pull/1", "https://github.com/o/r/pull/2"} {
+		if err := st.InsertPRMessage(t.Context(), prURL, "T1", "C1", "1.0"); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+
+	text := h.commandStatus(t.Context(), "https://github.com/o/r/pull/1", "T1")
+	if !strings.Contains(text, "On GitHub now: open; approved by alice; changes requested by bob") {
+		t.Fatalf("expected the live PR state, got %q", text)
+	}
+	text = h.commandStatus(t.Context(), "https://github.com/o/r/pull/2", "T1")
+	if !strings.Contains(text, "On GitHub now: could not be fetched") {
+		t.Fatalf("expected a failed fetch to be reported, got %q", text)
+	}
+	if reactions, err := st.ListPRReactions(t.Context(), "https://github.com/o/r/pull/1"); err != nil || len(reactions) != 0 {
+		t.Fatalf("status must not react: %v %v", reactions, err)
+	}
+}
+
+func TestSlackCommandIsScopedToTeam(t *testing.T) {
+
+	h := newTestHandlers(t, config.Config{SlackSigningSecret: "secret"})
+	st := h.Store
+	mux := http.NewServeMux()
+	h.Register(mux)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	const prURL = "https://github.com/o/r/pull/1"
+	if err := st.InsertPRMessage(t.Context(), prURL, "T1", "C1", "1.0"); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	if err := st.InsertPRMessage(t.Context(), prURL, "T2", "C9", "2.0"); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	if err := st.InsertPRReaction(t.Context(), prURL, "eyes"); err != nil {
+		t.Fatalf("record reaction: %v", err)
+	}
+
+	_, resp := slackCommandInTeam(t, srv, "secret", "T2", "C9", "status "+prURL)
+	if !strings.Contains(resp.Text, "tracked in 1 message(s) in <#C9>") {
+		t.Fatalf("status must only show the team's messages: %+v", resp)
+	}
+	if _, resp := slackCommandInTeam(t, srv, "secret", "T3", "C5", "untrack "+prURL); !strings.Contains(resp.Text, "is not tracked") {
+		t.Fatalf("untrack from another team must not delete anything: %+v", resp)
+	}
+	if _, resp := slackCommandInTeam(t, srv, "secret", "T2", "C9", "untrack "+prURL); resp.Text != "Stopped tracking "+prURL+" (1 message(s))." {
+		t.Fatalf("unexpected untrack response: %+v", resp)
+	}
+
+	msgs, err := st.ListMessagesByPRURL(t.Context(), prURL)
+	if err != nil || len(msgs) != 1 || msgs[0].TeamID != "T1" {
+		t.Fatalf("expected T1's message to survive, got %+v (%v)", msgs, err)
+	}
+	if reactions, err := st.ListPRReactions(t.Context(), prURL); err != nil || len(reactions) != 1 {
+		t.Fatalf("expected the reactions to stay while T1 tracks the PR, got %v (%v)", reactions, err)
+	}
+
+	if _, resp := slackCommandInTeam(t, srv, "secret", "T1", "C1", "untrack "+prURL); !strings.HasPrefix(resp.Text, "Stopped tracking") {
+		t.Fatalf("unexpected untrack response: %+v", resp)
+	}
+	if reactions, err := st.ListPRReactions(t.Context(), prURL); err != nil || len(reactions) != 0 {
+		t.Fatalf("expected the reactions to go with the last message, got %v (%v)", reactions, err)
+	}
+}
@@ -0,0 +1,102 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/adamantal/prmoji/internal/retry"
+	"github.com/adamantal/prmoji/internal/store"
+)
+
+// RetryFailedReactions runs one pass of the failed reaction retry loop.
+func (h *Handlers) RetryFailedReactions(ctx context.Context) (retry.Result, error) {
+	return retry.Run(ctx, h.Store, h.slackFor, retry.Options{MaxAttempts: h.settings().ReactionMaxAttempts})
+}
+
+func (h *Handlers) handleAdminListFailedReactions(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	total, err := h.Store.CountFailedReactions(r.Context())
+	if err != nil {
+		h.adminError(w, r, "count failed reactions failed", err)
+		return
+	}
+	items, err := h.Store.ListFailedReactions(r.Context(), limit, offset)
+	if err != nil {
+		h.adminError(w, r, "list failed reactions failed", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, page{Items: nonNil(items), Total: total, Limit: limit, Offset: offset})
+}
+
+func (h *Handlers) handleAdminRetryFailedReactions(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
+	defer cancel()
+
+	res, err := h.RetryFailedReactions(ctx)
+	if err != nil {
+		h.adminError(w, r, "retry failed reactions failed", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+type retryAttemptResult struct {
+	ID    int64  `json:"id"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// handleAdminRetryFailedReaction retries one failed reaction now, even if it was given up on.
+func (h *Handlers) handleAdminRetryFailedReaction(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 20*time.Second)
+	defer cancel()
+
+	fr, err := h.Store.GetFailedReaction(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "failed reaction not found"})
+		return
+	}
+	if err != nil {
+		h.adminError(w, r, "get failed reaction failed", err)
+		return
+	}
+
+	res := retryAttemptResult{ID: id, OK: true}
+	if err := retry.Attempt(ctx, h.Store, h.slackFor, fr); err != nil {
+		res.OK = false
+		res.Error = err.Error()
+	}
+	h.Log.Info("admin retried failed reaction", "id", id, "ok", res.OK)
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (h *Handlers) handleAdminDeleteFailedReaction(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	found, err := h.Store.DeleteFailedReaction(r.Context(), id)
+	if err != nil {
+		h.adminError(w, r, "delete failed reaction failed", err)
+		return
+	}
+	if !found {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "failed reaction not found"})
+		return
+	}
+	h.Log.Info("admin discarded failed reaction", "id", id)
+	w.WriteHeader(http.StatusNoContent)
+}
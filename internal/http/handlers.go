@@ -2,33 +2,66 @@ package http
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/adamantal/prmoji/internal/cleanup"
 	"github.com/adamantal/prmoji/internal/config"
 	"github.com/adamantal/prmoji/internal/github"
+	"github.com/adamantal/prmoji/internal/log"
+	"github.com/adamantal/prmoji/internal/metrics"
 	"github.com/adamantal/prmoji/internal/slack"
 	"github.com/adamantal/prmoji/internal/store"
-	"github.com/adamantal/prmoji/internal/util"
+	"github.com/adamantal/prmoji/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type Handlers struct {
 	Cfg   config.Config
 	Store *store.SQLiteStore
 	Slack *slack.Client
-	Log   *slog.Logger
+	// GitHub, when set, is used to react to newly posted PR links with the PR's current state.
+	GitHub *github.Client
+	Log    *slog.Logger
+
+	slackAuth    *authCache
+	channelNames channelNameCache
+	// reloaded holds the configuration once Reload replaced some of Cfg's settings.
+	reloaded atomic.Pointer[config.Config]
 }
 
 func (h *Handlers) Register(mux *http.ServeMux) {
+	h.slackAuth = &authCache{
+		check: func(ctx context.Context) (slack.AuthInfo, error) { return h.Slack.AuthTest(ctx) },
+		ttl:   slackAuthTTL,
+	}
+
 	mux.HandleFunc("GET /", h.handleOK)
 	mux.HandleFunc("GET /healthz", h.handleOK)
-	mux.HandleFunc("POST /event/slack", h.handleSlackEvent)
+	mux.HandleFunc("GET /readyz", h.handleReady)
+	if h.Cfg.SlackMode != config.SlackModeSocket {
+		mux.HandleFunc("POST /event/slack", h.handleSlackEvent)
+	}
 	mux.HandleFunc("POST /event/github", h.handleGitHubEvent)
 	mux.HandleFunc("POST /cleanup/", h.handleCleanup)
+	mux.Handle("GET /metrics", metrics.Handler())
+	if h.Cfg.SlackSigningSecret != "" {
+		mux.HandleFunc("POST /command/slack", h.handleSlackCommand)
+	}
+	if h.Cfg.SlackClientID != "" {
+		mux.HandleFunc("GET /slack/install", h.handleSlackInstall)
+		mux.HandleFunc("GET /slack/oauth/callback", h.handleSlackOAuthCallback)
+	}
+	if h.Cfg.AdminToken != "" {
+		h.registerAdmin(mux)
+	}
 }
 
 func (h *Handlers) handleOK(w http.ResponseWriter, _ *http.Request) {
@@ -37,140 +70,171 @@ func (h *Handlers) handleOK(w http.ResponseWriter, _ *http.Request) {
 }
 
 func (h *Handlers) handleSlackEvent(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Start(r.Context(), "handleSlackEvent")
+	defer span.End()
+
 	body, err := readBody(r, 1<<20)
 	if err != nil {
-		h.Log.Warn("read slack body failed", "err", err)
+		h.Log.WarnContext(ctx, "read slack body failed", "err", err)
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("OK"))
 		return
 	}
+	if h.Cfg.SlackSigningSecret != "" {
+		if err := slack.VerifySignature(h.Cfg.SlackSigningSecret, r.Header, body, time.Now()); err != nil {
+			h.Log.WarnContext(ctx, "rejected slack event", "err", err)
+			metrics.SlackEvents.WithLabelValues("other", "rejected").Inc()
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}
 
 	env, err := slack.ParseEnvelope(body)
 	if err != nil {
-		h.Log.Warn("parse slack payload failed", "err", err)
+		h.Log.WarnContext(ctx, "parse slack payload failed", "err", err)
+		metrics.SlackEvents.WithLabelValues("other", "parse_error").Inc()
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("OK"))
 		return
 	}
 
+	if env.EventID != "" {
+		// Slack retries keep the event_id, so it correlates better than a per-request ID.
+		ctx = log.WithRequestID(ctx, env.EventID)
+	}
+
 	if strings.TrimSpace(env.Challenge) != "" {
+		metrics.SlackEvents.WithLabelValues(slack.EventLabel(slackEventType(env)), "challenge").Inc()
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte(env.Challenge))
 		return
 	}
 
+	h.acceptSlackEvent(ctx, env, r.Header, body)
+
 	w.WriteHeader(http.StatusOK)
 	_, _ = w.Write([]byte("OK"))
-
-	go h.processSlackEvent(body)
 }
 
-func (h *Handlers) processSlackEvent(body []byte) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+// HandleSocketEvent feeds an Events API payload received over Socket Mode into the same path
+// as POST /event/slack.
+func (h *Handlers) HandleSocketEvent(ctx context.Context, payload []byte) {
+	ctx, span := tracing.Start(ctx, "handleSocketEvent")
+	defer span.End()
 
-	env, err := slack.ParseEnvelope(body)
+	env, err := slack.ParseEnvelope(payload)
 	if err != nil {
-		h.Log.Warn("parse slack payload failed", "err", err)
-		return
-	}
-	if env.Event.Text == "" || env.Event.Channel == "" || env.Event.EventTS == "" {
-		h.Log.Debug("discarding empty slack message", "event", env.Event)
-		return
-	}
-
-	urls := slack.ExtractPRURLs(env.Event.Text)
-	if len(urls) == 0 {
-		h.Log.Debug("discarding slack message without PR URLs", "channel", env.Event.Channel, "text", env.Event.Text)
+		h.Log.WarnContext(ctx, "parse slack payload failed", "err", err)
+		metrics.SlackEvents.WithLabelValues("other", "parse_error").Inc()
 		return
 	}
+	ctx = log.WithRequestID(ctx, env.EventID)
+	h.acceptSlackEvent(ctx, env, nil, payload)
+}
 
-	h.Log.Debug("ingesting slack message with PR URLs", "channel", env.Event.Channel, "count", len(urls))
-	for _, u := range urls {
-		if err := h.Store.InsertPRMessage(ctx, u, env.Event.Channel, env.Event.EventTS); err != nil {
-			h.Log.Error("insert pr message failed", "err", err, "pr_url", u)
-		}
-	}
+// acceptSlackEvent archives a Slack payload and processes it in the background.
+func (h *Handlers) acceptSlackEvent(ctx context.Context, env slack.EventEnvelope, header http.Header, body []byte) {
+	h.archive(ctx, sourceSlack, env.EventID, slackEventType(env), header, body)
 
-	h.Log.Info("slack message ingested", "count", len(urls), "channel", env.Event.Channel)
+	// The request context is canceled once we respond; keep only its span.
+	go h.processSlackEvent(context.WithoutCancel(ctx), body)
 }
 
 func (h *Handlers) handleGitHubEvent(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Start(r.Context(), "handleGitHubEvent")
+	defer span.End()
+
 	body, err := readBody(r, 2<<20)
 	if err != nil {
-		h.Log.Warn("read github body failed", "err", err)
+		h.Log.WarnContext(ctx, "read github body failed", "err", err)
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("OK"))
 		return
 	}
 
 	eventType := r.Header.Get("X-GitHub-Event")
+	span.SetAttributes(
+		attribute.String("github.event", eventType),
+		attribute.String("github.delivery", r.Header.Get("X-GitHub-Delivery")),
+	)
+	h.archive(ctx, sourceGitHub, r.Header.Get("X-GitHub-Delivery"), eventType, r.Header, body)
+
 	w.WriteHeader(http.StatusOK)
 	_, _ = w.Write([]byte("OK"))
 
-	go h.processGitHubEvent(eventType, body)
+	// The request context is canceled once we respond; keep only its span.
+	go h.processGitHubEvent(context.WithoutCancel(ctx), eventType, body)
 }
 
-func (h *Handlers) processGitHubEvent(eventType string, body []byte) {
-	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
-	defer cancel()
-
-	class, ok := github.Classify(eventType, body)
-	if !ok {
-		return
-	}
-	if class.PRURL == "" {
+func (h *Handlers) handleCleanup(w http.ResponseWriter, r *http.Request) {
+	opts, err := h.parseCleanupOptions(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 		return
 	}
 
-	if class.Action == github.ActionCommented {
-		who := strings.ToLower(strings.TrimSpace(class.Commenter))
-		for _, ignored := range h.Cfg.IgnoredCommenters {
-			if who != "" && who == ignored {
-				h.Log.Info("suppressed comment reaction", "pr_url", class.PRURL, "commenter", who)
-				return
-			}
-		}
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
 
-	emoji := util.EmojiForAction(class.Action)
-	msgs, err := h.Store.ListMessagesByPRURL(ctx, class.PRURL)
+	res, err := cleanup.Run(ctx, h.Store, opts, time.Now())
 	if err != nil {
-		h.Log.Error("list messages failed", "err", err, "pr_url", class.PRURL)
-		return
-	}
-	if len(msgs) == 0 {
+		h.Log.ErrorContext(ctx, "cleanup failed", "err", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "cleanup failed"})
 		return
 	}
+	writeJSON(w, http.StatusOK, res)
+}
 
-	for _, m := range msgs {
-		if err := h.Slack.AddReaction(ctx, m.MessageChannel, m.MessageTimestamp, emoji); err != nil {
-			h.Log.Error("add reaction failed", "err", err, "pr_url", class.PRURL, "channel", m.MessageChannel, "ts", m.MessageTimestamp, "emoji", emoji)
+// parseCleanupOptions reads the optional days, policy and dry_run query parameters,
+// falling back to the configured retention settings.
+func (h *Handlers) parseCleanupOptions(r *http.Request) (cleanup.Options, error) {
+	q := r.URL.Query()
+	opts := h.CleanupOptions()
+
+	if raw := strings.TrimSpace(q.Get("days")); raw != "" {
+		days, err := strconv.Atoi(raw)
+		if err != nil {
+			return cleanup.Options{}, fmt.Errorf("invalid days: %q", raw)
 		}
+		opts.RetentionDays = days
+	}
+	if minDays := h.settings().CleanupMinDays; opts.RetentionDays < minDays {
+		return cleanup.Options{}, fmt.Errorf("days must be at least %d", minDays)
 	}
 
-	if class.Action == github.ActionMerged || class.Action == github.ActionClosed {
-		if err := h.Store.DeleteByPRURL(ctx, class.PRURL); err != nil {
-			h.Log.Error("delete mappings failed", "err", err, "pr_url", class.PRURL)
+	if raw := strings.TrimSpace(q.Get("policy")); raw != "" {
+		policy, err := config.ParseRetentionPolicy(raw)
+		if err != nil {
+			return cleanup.Options{}, fmt.Errorf("invalid policy: %q", raw)
 		}
+		opts.Policy = policy
 	}
 
-	h.Log.Info("processed github event", "event", eventType, "action", string(class.Action), "pr_url", class.PRURL, "messages", len(msgs))
+	if raw := strings.TrimSpace(q.Get("dry_run")); raw != "" {
+		dryRun, err := strconv.ParseBool(raw)
+		if err != nil {
+			return cleanup.Options{}, fmt.Errorf("invalid dry_run: %q", raw)
+		}
+		opts.DryRun = dryRun
+	}
+	return opts, nil
 }
 
-func (h *Handlers) handleCleanup(w http.ResponseWriter, r *http.Request) {
-	_ = r
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
 
-	_, err := cleanup.Run(ctx, h.Store, h.Cfg.RetentionDays, time.Now())
-	if err != nil {
-		h.Log.Error("cleanup failed", "err", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+func slackEventType(env slack.EventEnvelope) string {
+	switch {
+	case env.Event.Type != "":
+		return env.Event.Type
+	case env.Type != "":
+		return env.Type
+	default:
+		return "unknown"
 	}
-	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write([]byte("OK"))
 }
 
 func readBody(r *http.Request, limit int64) ([]byte, error) {
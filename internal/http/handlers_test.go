@@ -0,0 +1,160 @@
+package http
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/adamantal/prmoji/internal/config"
+	"github.com/adamantal/prmoji/internal/slack"
+	"github.com/adamantal/prmoji/internal/store"
+)
+
+// newTestHandlers returns handlers for cfg backed by a fresh store, without Slack or GitHub clients.
+func newTestHandlers(t *testing.T, cfg config.Config) *Handlers {
+	t.Helper()
+	st, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "prmoji.db"))
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+	return &Handlers{Cfg: cfg, Store: st, Log: slog.New(slog.NewTextHandler(io.Discard, nil))}
+}
+
+// fakeSlack is a Slack Web API stand-in that records the calls made to it as
+// "method channel ts name" and rejects every call for channel C0FAIL.
+type fakeSlack struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+// newFakeSlack points h at a new fakeSlack.
+func newFakeSlack(t *testing.T, h *Handlers) *fakeSlack {
+	t.Helper()
+	f := &fakeSlack{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method := strings.TrimPrefix(r.URL.Path, "/")
+		f.mu.Lock()
+		f.calls = append(f.calls, strings.TrimSpace(strings.Join([]string{method, r.FormValue("channel"), r.FormValue("timestamp"), r.FormValue("name")}, " ")))
+		f.mu.Unlock()
+		if r.FormValue("channel") == "C0FAIL" {
+			_, _ = w.Write([]byte(`{"ok":false,"error":"channel_not_found"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	t.Cleanup(srv.Close)
+	sc, err := slack.NewClientWithOptions("xoxb-test", slack.Options{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("new slack client: %v", err)
+	}
+	h.Slack = sc
+	return f
+}
+
+func (f *fakeSlack) Calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.calls...)
+}
+
+func TestParseCleanupOptions(t *testing.T) {
+	h := &Handlers{Cfg: config.Config{RetentionDays: 90, RetentionPolicy: config.RetentionInactivity, CleanupMinDays: 7}}
+
+	t.Run("defaults to configured retention", func(t *testing.T) {
+		opts, err := h.parseCleanupOptions(httptest.NewRequest("POST", "/cleanup/", nil))
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if opts.RetentionDays != 90 || opts.Policy != config.RetentionInactivity || opts.DryRun {
+			t.Fatalf("unexpected options: %#v", opts)
+		}
+	})
+
+	t.Run("accepts days and dry_run", func(t *testing.T) {
+		opts, err := h.parseCleanupOptions(httptest.NewRequest("POST", "/cleanup/?days=30&dry_run=true", nil))
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if opts.RetentionDays != 30 || !opts.DryRun {
+			t.Fatalf("unexpected options: %#v", opts)
+		}
+	})
+
+	t.Run("accepts policy override", func(t *testing.T) {
+		opts, err := h.parseCleanupOptions(httptest.NewRequest("POST", "/cleanup/?policy=inserted", nil))
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if opts.Policy != config.RetentionInserted {
+			t.Fatalf("unexpected policy: %q", opts.Policy)
+		}
+	})
+
+	t.Run("rejects days below minimum", func(t *testing.T) {
+		if _, err := h.parseCleanupOptions(httptest.NewRequest("POST", "/cleanup/?days=3", nil)); err == nil {
+			t.Fatalf("expected error")
+		}
+	})
+
+	t.Run("rejects malformed values", func(t *testing.T) {
+		if _, err := h.parseCleanupOptions(httptest.NewRequest("POST", "/cleanup/?days=abc", nil)); err == nil {
+			t.Fatalf("expected error for days")
+		}
+		if _, err := h.parseCleanupOptions(httptest.NewRequest("POST", "/cleanup/?dry_run=maybe", nil)); err == nil {
+			t.Fatalf("expected error for dry_run")
+		}
+		if _, err := h.parseCleanupOptions(httptest.NewRequest("POST", "/cleanup/?policy=forever", nil)); err == nil {
+			t.Fatalf("expected error for policy")
+		}
+	})
+}
+
+func TestSlackEventSignature(t *testing.T) {
+	h := newTestHandlers(t, config.Config{SlackSigningSecret: "secret"})
+	mux := http.NewServeMux()
+	h.Register(mux)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	body := `{"type":"url_verification","challenge":"abc"}`
+	post := func(secret string, at time.Time) (int, string) {
+		t.Helper()
+		req, err := http.NewRequest("POST", srv.URL+"/event/slack", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("new request: %v", err)
+		}
+		if secret != "" {
+			ts := strconv.FormatInt(at.Unix(), 10)
+			req.Header.Set("X-Slack-Request-Timestamp", ts)
+			req.Header.Set("X-Slack-Signature", slack.Signature(secret, ts, []byte(body)))
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("do request: %v", err)
+		}
+		defer resp.Body.Close()
+		out, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(out)
+	}
+
+	if code, _ := post("", time.Now()); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without a signature got %d", code)
+	}
+	if code, _ := post("wrong", time.Now()); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a bad signature got %d", code)
+	}
+	if code, _ := post("secret", time.Now().Add(-time.Hour)); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a stale timestamp got %d", code)
+	}
+	if code, out := post("secret", time.Now()); code != http.StatusOK || out != "abc" {
+		t.Fatalf("expected the challenge to be echoed got %d %q", code, out)
+	}
+}
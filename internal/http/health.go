@@ -0,0 +1,79 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/adamantal/prmoji/internal/slack"
+)
+
+// slackAuthTTL is how long a Slack auth.test result is reused by /readyz.
+const slackAuthTTL = time.Minute
+
+type checkResult struct {
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+type readyResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]checkResult `json:"checks"`
+}
+
+// authCache rate-limits auth.test calls: probes run every few seconds, Slack tier limits don't.
+type authCache struct {
+	mu      sync.Mutex
+	check   func(context.Context) (slack.AuthInfo, error)
+	ttl     time.Duration
+	last    checkResult
+	checked bool
+}
+
+func (c *authCache) get(ctx context.Context) checkResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.checked && time.Since(c.last.CheckedAt) < c.ttl {
+		return c.last
+	}
+
+	res := checkResult{Status: "ok", CheckedAt: time.Now().UTC()}
+	info, err := c.check(ctx)
+	switch {
+	case err != nil:
+		res.Status, res.Error = "fail", err.Error()
+	case !info.HasScope(slack.ScopeReactionsWrite):
+		res.Status, res.Error = "fail", "token lacks "+slack.ScopeReactionsWrite
+	}
+	c.last, c.checked = res, true
+	return res
+}
+
+func (h *Handlers) handleReady(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	resp := readyResponse{Status: "ok", Checks: map[string]checkResult{}}
+
+	storeCheck := checkResult{Status: "ok", CheckedAt: time.Now().UTC()}
+	if err := h.Store.Ping(ctx); err != nil {
+		storeCheck.Status, storeCheck.Error = "fail", err.Error()
+	}
+	resp.Checks["store"] = storeCheck
+	// Without SLACK_TOKEN every workspace has its own token from an OAuth install.
+	if h.Cfg.SlackToken != "" || h.Cfg.SlackClientID == "" {
+		resp.Checks["slack"] = h.slackAuth.get(ctx)
+	}
+
+	status := http.StatusOK
+	for name, c := range resp.Checks {
+		if c.Status != "ok" {
+			h.Log.Warn("readiness check failed", "check", name, "err", c.Error)
+			resp.Status = "fail"
+			status = http.StatusServiceUnavailable
+		}
+	}
+	writeJSON(w, status, resp)
+}
@@ -0,0 +1,111 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/adamantal/prmoji/internal/metrics"
+	"github.com/adamantal/prmoji/internal/slack"
+)
+
+const mentionHelp = "Mention me in a thread: `track <PR URL>` reacts to the thread's first message for that PR, `stop` stops reacting to the thread."
+
+// isMentionCommand reports whether the words after a mention of the bot are a command for it.
+// Other mentions, like "@prmoji please review <PR URL>", are ingested as plain messages.
+func isMentionCommand(words []string) bool {
+	if len(words) == 0 {
+		return false
+	}
+	switch strings.ToLower(words[0]) {
+	case "track", "stop", "help":
+		return true
+	}
+	return false
+}
+
+// processAppMention handles "@prmoji track <PR URL>" and "@prmoji stop" in a thread. Both act on
+// the thread and reply in it.
+func (h *Handlers) processAppMention(ctx context.Context, env slack.EventEnvelope, res Result) Result {
+	ev := env.Event
+	_, words, _ := slack.ParseMention(ev.Text)
+	cmd := ""
+	if len(words) > 0 {
+		cmd = strings.ToLower(words[0])
+		res.Action = cmd
+	}
+	outcome := func(o, reply string) Result {
+		countSlackEvent(ctx, ev.Type, o)
+		res.Outcome = o
+		h.replyInThread(ctx, env.TeamID, ev, reply, res.DryRun)
+		return res
+	}
+
+	// The message copy of the mention tracks its PR links.
+	if !isMentionCommand(words) && len(slack.ExtractPRURLs(ev.Text)) > 0 {
+		return outcome("not_command", "")
+	}
+	if (cmd != "track" && cmd != "stop") || !ev.InThread() {
+		return outcome("usage", mentionHelp)
+	}
+
+	if cmd == "stop" {
+		if res.DryRun {
+			h.skipped(ctx, "store_write", "untrack slack message", "channel", ev.Channel, "ts", ev.ThreadTS)
+			return outcome("untracked", "OK, I stopped tracking this thread.")
+		}
+		n, err := h.Store.DeleteSlackThread(ctx, ev.Channel, ev.ThreadTS)
+		if err != nil {
+			h.Log.ErrorContext(ctx, "untrack slack message failed", "err", err, "channel", ev.Channel, "ts", ev.ThreadTS)
+			return outcome("error", "Something went wrong, please try again.")
+		}
+		h.Log.InfoContext(ctx, "untracked slack message", "channel", ev.Channel, "ts", ev.ThreadTS, "user", ev.User, "prs", n)
+		if n == 0 {
+			return outcome("untracked", "I wasn't tracking any PRs on this thread.")
+		}
+		return outcome("untracked", fmt.Sprintf("OK, I stopped tracking %d PR link(s) on this thread.", n))
+	}
+
+	urls := slack.ExtractPRURLs(strings.Join(words[1:], " "))
+	if len(urls) == 0 {
+		return outcome("usage", mentionHelp)
+	}
+	res.PRURLs = urls
+	for _, u := range urls {
+		if res.DryRun {
+			h.skipped(ctx, "store_write", "track pr message", "pr_url", u, "channel", ev.Channel, "ts", ev.ThreadTS)
+			continue
+		}
+		inserted, err := h.Store.TrackPRMessage(ctx, u, env.TeamID, ev.Channel, ev.ThreadTS)
+		if err != nil {
+			h.Log.ErrorContext(ctx, "insert pr message failed", "err", err, "pr_url", u)
+			return outcome("error", "Something went wrong, please try again.")
+		}
+		if inserted {
+			metrics.PRURLsIngested.Inc()
+		}
+	}
+	h.Log.InfoContext(ctx, "tracked pr on thread", "channel", ev.Channel, "ts", ev.ThreadTS, "user", ev.User, "count", len(urls))
+	return outcome("tracked", "OK, I'll react to this thread's first message for "+strings.Join(urls, ", ")+".")
+}
+
+func (h *Handlers) replyInThread(ctx context.Context, teamID string, ev slack.SlackEvent, text string, dryRun bool) {
+	if text == "" {
+		return
+	}
+	threadTS := ev.ThreadTS
+	if threadTS == "" {
+		threadTS = ev.TS
+	}
+	if dryRun {
+		h.skipped(ctx, "message", "reply", "channel", ev.Channel, "thread_ts", threadTS, "text", text)
+		return
+	}
+	sc, err := h.slackFor(ctx, teamID)
+	if err == nil {
+		err = sc.PostMessage(ctx, ev.Channel, threadTS, text)
+	}
+	if err != nil {
+		h.Log.ErrorContext(ctx, "reply to mention failed", "err", err, "channel", ev.Channel, "thread_ts", threadTS)
+	}
+}
@@ -0,0 +1,95 @@
+package http
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/adamantal/prmoji/internal/config"
+)
+
+func TestProcessAppMentionDryRun(t *testing.T) {
+	h := newTestHandlers(t, config.Config{})
+	st := h.Store
+	ctx := withDryRun(context.Background())
+
+	const prURL = "https://github.com/o/r/pull/1"
+	event := func(typ, text, ts, threadTS string) []byte {
+		return []byte(`{"type":"event_callback","authorizations":[{"user_id":"U0BOT","is_bot":true}],` +
+			`"event":{"type":"` + typ + `","text":"` + text + `","channel":"C1","ts":"` + ts + `","thread_ts":"` + threadTS + `","event_ts":"` + ts + `"}}`)
+	}
+
+	tests := []struct {
+		name    string
+		body    []byte
+		outcome string
+		prURLs  int
+	}{
+		{"track in thread", event("app_mention", "<@U0BOT> track <"+prURL+">", "2.0", "1.0"), "tracked", 1},
+		{"stop in thread", event("app_mention", "<@U0BOT> stop", "2.0", "1.0"), "untracked", 0},
+		{"track outside thread", event("app_mention", "<@U0BOT> track "+prURL, "2.0", ""), "usage", 0},
+		{"unknown command", event("app_mention", "<@U0BOT> hello", "2.0", "1.0"), "usage", 0},
+		{"message copy of the mention", event("message", "<@U0BOT> track "+prURL, "2.0", "1.0"), "mention", 0},
+		{"message mentioning someone else", event("message", "<@U0BOB> look at "+prURL, "2.0", ""), "ingested", 1},
+		{"mention that isn't a command", event("app_mention", "<@U0BOT> please review "+prURL, "2.0", ""), "not_command", 0},
+		{"message copy of a mention that isn't a command", event("message", "<@U0BOT> please review "+prURL, "2.0", ""), "ingested", 1},
+		{"message copy of help", event("message", "<@U0BOT> help", "2.0", ""), "mention", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := h.processSlackEvent(ctx, tt.body)
+			if res.Outcome != tt.outcome || len(res.PRURLs) != tt.prURLs {
+				t.Fatalf("expected %s with %d PR URLs got %+v", tt.outcome, tt.prURLs, res)
+			}
+		})
+	}
+
+	n, err := st.CountTrackedPRs(context.Background())
+	if err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if n != 0 {
+		t.Fatalf("dry run must not track PRs, got %d", n)
+	}
+}
+
+func TestProcessAppMentionStopThread(t *testing.T) {
+	h := newTestHandlers(t, config.Config{})
+	slack := newFakeSlack(t, h)
+	st := h.Store
+	ctx := context.Background()
+
+	const prURL = "https://github.com/o/r/pull/1"
+	for _, m := range []struct{ channel, ts, threadTS string }{
+		{"C1", "1.0", ""},
+		{"C1", "2.0", "1.0"},
+		{"C1", "3.0", "1.0"},
+		{"C1", "4.0", ""},
+		{"C2", "5.0", "1.0"},
+	} {
+		if err := st.InsertPRThreadReply(ctx, prURL, "T1", m.channel, m.ts, m.threadTS); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+
+	body := []byte(`{"type":"event_callback","team_id":"T1","authorizations":[{"user_id":"U0BOT","is_bot":true}],` +
+		`"event":{"type":"app_mention","text":"<@U0BOT> stop","channel":"C1","ts":"9.0","thread_ts":"1.0","event_ts":"9.0"}}`)
+	if res := h.processSlackEvent(ctx, body); res.Outcome != "untracked" {
+		t.Fatalf("expected untracked got %+v", res)
+	}
+	if calls := slack.Calls(); len(calls) != 1 || calls[0] != "chat.postMessage C1" {
+		t.Fatalf("expected one reply in the thread, got %v", calls)
+	}
+
+	msgs, err := st.ListMessagesByPRURL(ctx, prURL)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	var left []string
+	for _, m := range msgs {
+		left = append(left, m.MessageChannel+"/"+m.MessageTimestamp)
+	}
+	if strings.Join(left, ",") != "C1/4.0,C2/5.0" {
+		t.Fatalf("expected the thread's parent and replies to be untracked, left %v", left)
+	}
+}
@@ -0,0 +1,292 @@
+package http
+
+import (
+	"context"
+	"time"
+
+	"github.com/adamantal/prmoji/internal/github"
+	"github.com/adamantal/prmoji/internal/metrics"
+	"github.com/adamantal/prmoji/internal/slack"
+	"github.com/adamantal/prmoji/internal/store"
+	"github.com/adamantal/prmoji/internal/suppress"
+	"github.com/adamantal/prmoji/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Result describes what processing one webhook payload did, or would have done in dry-run mode.
+type Result struct {
+	Source    string     `json:"source"`
+	Outcome   string     `json:"outcome"`
+	Action    string     `json:"action,omitempty"`
+	PRURLs    []string   `json:"pr_urls,omitempty"`
+	Reactions []Reaction `json:"reactions,omitempty"`
+	DryRun    bool       `json:"dry_run"`
+}
+
+type Reaction struct {
+	Channel string `json:"channel"`
+	TS      string `json:"ts"`
+	Emoji   string `json:"emoji"`
+	Error   string `json:"error,omitempty"`
+	// DueAt is set when the reaction was queued instead of added.
+	DueAt time.Time `json:"due_at,omitzero"`
+}
+
+type dryRunKey struct{}
+
+// withDryRun marks ctx so that processing only logs the Slack calls and store writes it would make.
+func withDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, true)
+}
+
+func isDryRun(ctx context.Context) bool {
+	dry, _ := ctx.Value(dryRunKey{}).(bool)
+	return dry
+}
+
+// dryRunContext marks ctx for dry-run processing when DRY_RUN is set.
+func (h *Handlers) dryRunContext(ctx context.Context) context.Context {
+	if h.settings().DryRun {
+		return withDryRun(ctx)
+	}
+	return ctx
+}
+
+// skipped logs and counts a Slack call or store write held back in dry-run mode; kind is
+// reaction, message or store_write.
+func (h *Handlers) skipped(ctx context.Context, kind, msg string, args ...any) {
+	metrics.DryRunActions.WithLabelValues(kind).Inc()
+	h.Log.InfoContext(ctx, "dry run: would "+msg, args...)
+}
+
+// countSlackEvent counts a processed Slack event by outcome. Dry runs are left out, so
+// replays and DRY_RUN don't inflate the live event metrics.
+func countSlackEvent(ctx context.Context, eventType, outcome string) {
+	if !isDryRun(ctx) {
+		metrics.SlackEvents.WithLabelValues(slack.EventLabel(eventType), outcome).Inc()
+	}
+}
+
+func (h *Handlers) processSlackEvent(ctx context.Context, body []byte) Result {
+	defer metrics.TrackInFlight("slack")()
+	ctx, span := tracing.Start(ctx, "processSlackEvent")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(h.dryRunContext(ctx), 10*time.Second)
+	defer cancel()
+
+	res := Result{Source: sourceSlack, DryRun: isDryRun(ctx)}
+	env, err := slack.ParseEnvelope(body)
+	if err != nil {
+		h.Log.WarnContext(ctx, "parse slack payload failed", "err", err)
+		res.Outcome = "parse_error"
+		return res
+	}
+	eventType := slackEventType(env)
+	if eventType == "app_uninstalled" {
+		return h.processAppUninstalled(ctx, env, res)
+	}
+	if env.Event.Text == "" || env.Event.Channel == "" || env.Event.EventTS == "" {
+		h.Log.DebugContext(ctx, "discarding empty slack message", "event", env.Event)
+		countSlackEvent(ctx, eventType, "empty")
+		res.Outcome = "empty"
+		return res
+	}
+
+	if eventType == "app_mention" {
+		return h.processAppMention(ctx, env, res)
+	}
+	// Mentions also arrive as plain messages; leave commands addressed to us to processAppMention.
+	if botID := env.BotUserID(); botID != "" {
+		if userID, words, ok := slack.ParseMention(env.Event.Text); ok && userID == botID && isMentionCommand(words) {
+			countSlackEvent(ctx, eventType, "mention")
+			res.Outcome = "mention"
+			return res
+		}
+	}
+
+	urls := slack.ExtractPRURLs(env.Event.Text)
+	if len(urls) == 0 {
+		h.Log.DebugContext(ctx, "discarding slack message without PR URLs", "channel", env.Event.Channel, "text", env.Event.Text)
+		countSlackEvent(ctx, eventType, "no_pr_urls")
+		res.Outcome = "no_pr_urls"
+		return res
+	}
+	if urls = h.routedURLs(ctx, env.TeamID, env.Event.Channel, urls); len(urls) == 0 {
+		h.Log.DebugContext(ctx, "discarding slack message without PR URLs routed to its channel", "channel", env.Event.Channel)
+		countSlackEvent(ctx, eventType, "not_routed")
+		res.Outcome = "not_routed"
+		return res
+	}
+	res.PRURLs = urls
+
+	h.Log.DebugContext(ctx, "ingesting slack message with PR URLs", "channel", env.Event.Channel, "count", len(urls))
+	// Only tracked links get the PR's current state; one that failed to insert would get
+	// reactions no later webhook can follow up on.
+	tracked := make([]string, 0, len(urls))
+	for _, u := range urls {
+		if res.DryRun {
+			h.skipped(ctx, "store_write", "track pr message", "pr_url", u, "channel", env.Event.Channel, "ts", env.Event.EventTS)
+			tracked = append(tracked, u)
+			continue
+		}
+		if err := h.Store.InsertPRThreadReply(ctx, u, env.TeamID, env.Event.Channel, env.Event.EventTS, env.Event.ReplyTo()); err != nil {
+			h.Log.ErrorContext(ctx, "insert pr message failed", "err", err, "pr_url", u)
+			continue
+		}
+		tracked = append(tracked, u)
+		metrics.PRURLsIngested.Inc()
+	}
+	if h.GitHub != nil {
+		for _, u := range tracked {
+			res.Reactions = append(res.Reactions, h.applyPRState(ctx, u, env.TeamID, env.Event.Channel, env.Event.EventTS)...)
+		}
+	}
+	countSlackEvent(ctx, eventType, "ingested")
+	res.Outcome = "ingested"
+
+	h.Log.InfoContext(ctx, "slack message ingested", "count", len(urls), "channel", env.Event.Channel)
+	return res
+}
+
+func (h *Handlers) processGitHubEvent(ctx context.Context, eventType string, body []byte) Result {
+	defer metrics.TrackInFlight("github")()
+	ctx, span := tracing.Start(ctx, "processGitHubEvent", attribute.String("github.event", eventType))
+	defer span.End()
+	ctx, cancel := context.WithTimeout(h.dryRunContext(ctx), 20*time.Second)
+	defer cancel()
+
+	res := Result{Source: sourceGitHub, DryRun: isDryRun(ctx)}
+	eventLabel := github.EventLabel(eventType)
+	outcome := func(o string) Result {
+		if !res.DryRun {
+			metrics.GitHubEvents.WithLabelValues(eventLabel, o).Inc()
+		}
+		span.SetAttributes(attribute.String("prmoji.outcome", o))
+		res.Outcome = o
+		return res
+	}
+
+	// Org-level webhooks deliver every repository's events; drop the unwanted ones before touching the store.
+	if repo := github.Repository(body); repo != "" {
+		span.SetAttributes(attribute.String("github.repository", repo))
+		if !h.settings().GitHubRepos.Allows(repo) {
+			h.Log.DebugContext(ctx, "filtered github event", "event", eventType, "repository", repo)
+			return outcome("filtered")
+		}
+	}
+
+	_, classifySpan := tracing.Start(ctx, "github.Classify")
+	class, ok := github.Classify(eventType, body)
+	classifySpan.SetAttributes(
+		attribute.Bool("github.classified", ok),
+		attribute.String("github.action", string(class.Action)),
+		attribute.String("github.pr_url", class.PRURL),
+	)
+	classifySpan.End()
+	if !ok {
+		return outcome("ignored")
+	}
+	if class.PRURL == "" {
+		return outcome("no_pr_url")
+	}
+	span.SetAttributes(attribute.String("github.pr_url", class.PRURL))
+	res.Action = string(class.Action)
+	res.PRURLs = []string{class.PRURL}
+
+	if res.DryRun {
+		h.skipped(ctx, "store_write", "touch pr activity", "pr_url", class.PRURL)
+	} else if err := h.Store.TouchPRActivity(ctx, class.PRURL); err != nil {
+		h.Log.ErrorContext(ctx, "touch pr activity failed", "err", err, "pr_url", class.PRURL)
+	}
+
+	if rule, ok := h.settings().IgnoredCommenters.Match(suppressEvent(class)); ok {
+		h.Log.InfoContext(ctx, "suppressed reaction", "pr_url", class.PRURL, "action", string(class.Action), "actor", class.Actor(), "rule", rule.Raw)
+		// The PR is still finished, so its mappings go even though nobody hears about it.
+		if class.Action == github.ActionMerged || class.Action == github.ActionClosed {
+			h.deleteMappings(ctx, class.PRURL)
+		}
+		return outcome("suppressed")
+	}
+
+	emoji := h.settings().Emojis.For(class.Action)
+	msgs, err := h.Store.ListMessagesByPRURL(ctx, class.PRURL)
+	if err != nil {
+		h.Log.ErrorContext(ctx, "list messages failed", "err", err, "pr_url", class.PRURL)
+		res.Outcome = "error"
+		return res
+	}
+	if len(msgs) == 0 {
+		return outcome("untracked")
+	}
+	outcome(string(class.Action))
+
+	if class.Action != github.ActionMerged && class.Action != github.ActionClosed {
+		h.recordReaction(ctx, class.PRURL, emoji)
+	}
+
+	// Forgetting a finished PR drops its queued reactions, so it happens before the final
+	// reaction is delivered or queued.
+	if class.Action == github.ActionMerged || class.Action == github.ActionClosed {
+		h.deleteMappings(ctx, class.PRURL)
+	}
+
+	h.dropSuperseded(ctx, class.PRURL, class.Action)
+	for _, m := range msgs {
+		res.Reactions = append(res.Reactions, h.deliver(ctx, class.PRURL, m.TeamID, m.MessageChannel, m.MessageTimestamp, emoji))
+	}
+
+	h.Log.InfoContext(ctx, "processed github event", "event", eventType, "action", string(class.Action), "pr_url", class.PRURL, "messages", len(msgs))
+	return res
+}
+
+func suppressEvent(c github.Classification) suppress.Event {
+	ev := suppress.Event{Action: c.Action, Actor: c.Actor(), ActorIsBot: c.ActorIsBot(), Author: c.Author}
+	if pr, ok := github.ParsePRURL(c.PRURL); ok {
+		ev.Repo = pr.Owner + "/" + pr.Repo
+	}
+	return ev
+}
+
+// recordReaction remembers that emoji was added for prURL, so that it can be re-applied.
+func (h *Handlers) recordReaction(ctx context.Context, prURL, emoji string) {
+	if isDryRun(ctx) {
+		h.skipped(ctx, "store_write", "record pr reaction", "pr_url", prURL, "emoji", emoji)
+		return
+	}
+	if err := h.Store.InsertPRReaction(ctx, prURL, emoji); err != nil {
+		h.Log.ErrorContext(ctx, "record pr reaction failed", "err", err, "pr_url", prURL, "emoji", emoji)
+	}
+}
+
+// deleteMappings forgets a finished PR.
+func (h *Handlers) deleteMappings(ctx context.Context, prURL string) {
+	if isDryRun(ctx) {
+		h.skipped(ctx, "store_write", "delete mappings", "pr_url", prURL)
+		return
+	}
+	if err := h.Store.DeleteByPRURL(ctx, prURL); err != nil {
+		h.Log.ErrorContext(ctx, "delete mappings failed", "err", err, "pr_url", prURL)
+	}
+}
+
+// react adds emoji to a message in workspace teamID, recording a failure for the retry loop.
+func (h *Handlers) react(ctx context.Context, prURL, teamID, channel, ts, emoji string) Reaction {
+	r := Reaction{Channel: channel, TS: ts, Emoji: emoji}
+	if isDryRun(ctx) {
+		h.skipped(ctx, "reaction", "add reaction", "pr_url", prURL, "team_id", teamID, "channel", channel, "ts", ts, "emoji", emoji)
+		return r
+	}
+	sc, err := h.slackFor(ctx, teamID)
+	if err == nil {
+		err = sc.AddReaction(ctx, channel, ts, emoji)
+	}
+	if err != nil {
+		h.Log.ErrorContext(ctx, "add reaction failed", "err", err, "pr_url", prURL, "team_id", teamID, "channel", channel, "ts", ts, "emoji", emoji)
+		r.Error = err.Error()
+		fr := store.FailedReaction{PRURL: prURL, TeamID: teamID, Channel: channel, TS: ts, Emoji: emoji, Error: r.Error}
+		if err := h.Store.RecordFailedReaction(ctx, fr); err != nil {
+			h.Log.ErrorContext(ctx, "record failed reaction failed", "err", err, "pr_url", prURL)
+		}
+	}
+	return r
+}
@@ -0,0 +1,156 @@
+package http
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/adamantal/prmoji/internal/config"
+	"github.com/adamantal/prmoji/internal/github"
+	"github.com/adamantal/prmoji/internal/metrics"
+	"github.com/adamantal/prmoji/internal/routing"
+	"github.com/adamantal/prmoji/internal/suppress"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestProcessGitHubEventSuppressed(t *testing.T) {
+	rules, err := suppress.Parse("{bot}:*")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	h := newTestHandlers(t, config.Config{IgnoredCommenters: rules})
+	st := h.Store
+	ctx := t.Context()
+
+	prURL := "https://github.com/o/r/pull/1"
+	if err := st.InsertPRMessage(ctx, prURL, "T1", "C1", "1.0"); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	body := `{"action":"closed","pull_request":{"html_url":"` + prURL + `","merged":true},"sender":{"login":"mergify[bot]","type":"Bot"}}`
+	res := h.processGitHubEvent(ctx, "pull_request", []byte(body))
+	if res.Outcome != "suppressed" || len(res.Reactions) != 0 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	msgs, err := st.ListMessagesByPRURL(ctx, prURL)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(msgs) != 0 {
+		t.Fatalf("a suppressed merge must still untrack the PR, got %d messages", len(msgs))
+	}
+}
+
+func TestProcessGitHubEventFilteredRepository(t *testing.T) {
+	repos, err := routing.ParseRepoFilter("o/*,!o/archive")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	h := newTestHandlers(t, config.Config{GitHubRepos: repos})
+	ctx := t.Context()
+
+	for repo, want := range map[string]string{"o/archive": "filtered", "other/r": "filtered", "o/r": "untracked"} {
+		body := `{"action":"closed","pull_request":{"html_url":"https://github.com/` + repo + `/pull/1","merged":true},"repository":{"full_name":"` + repo + `"}}`
+		if res := h.processGitHubEvent(ctx, "pull_request", []byte(body)); res.Outcome != want {
+			t.Fatalf("%s: expected %q got %q", repo, want, res.Outcome)
+		}
+	}
+}
+
+func TestProcessGitHubEventDebounced(t *testing.T) {
+	h := newTestHandlers(t, config.Config{ReactionDebounce: time.Minute})
+	st := h.Store
+	ctx := t.Context()
+
+	prURL := "https://github.com/o/r/pull/1"
+	if err := st.InsertPRMessage(ctx, prURL, "T1", "C1", "1.0"); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	for _, state := range []string{"approved", "commented", "changes_requested"} {
+		body := `{"action":"submitted","review":{"state":"` + state + `","user":{"login":"alice"}},"pull_request":{"html_url":"` + prURL + `"}}`
+		res := h.processGitHubEvent(ctx, "pull_request_review", []byte(body))
+		if len(res.Reactions) != 1 || res.Reactions[0].DueAt.IsZero() {
+			t.Fatalf("%s: expected a queued reaction, got %+v", state, res.Reactions)
+		}
+	}
+
+	queued, err := st.ListDueReactions(ctx, time.Now().Add(time.Hour), 10)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	var emojis []string
+	for _, qr := range queued {
+		emojis = append(emojis, qr.Emoji)
+	}
+	if len(emojis) != 2 || emojis[0] != "speech_balloon" || emojis[1] != "no_entry" {
+		t.Fatalf("expected the approval to be superseded, got %v", emojis)
+	}
+	if n, err := h.FlushQueuedReactions(ctx); err != nil || n != 0 {
+		t.Fatalf("nothing is due yet, flushed %d (%v)", n, err)
+	}
+
+	body := `{"action":"closed","pull_request":{"html_url":"` + prURL + `","merged":true}}`
+	if res := h.processGitHubEvent(ctx, "pull_request", []byte(body)); res.Outcome != "merged" {
+		t.Fatalf("unexpected merge result: %+v", res)
+	}
+	queued, err = st.ListDueReactions(ctx, time.Now().Add(time.Hour), 10)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if want := h.settings().Emojis.For(github.ActionMerged); len(queued) != 1 || queued[0].Emoji != want {
+		t.Fatalf("expected only the %s reaction to stay queued after the merge, got %+v", want, queued)
+	}
+}
+
+func TestProcessDryRunSetting(t *testing.T) {
+	// No Slack client: any Slack call would fail the reaction.
+	h := newTestHandlers(t, config.Config{DryRun: true})
+	st := h.Store
+	ctx := t.Context()
+
+	const prURL = "https://github.com/o/r/pull/1"
+	msg := `{"type":"event_callback","event":{"type":"message","text":"<` + prURL + `>","channel":"C1","ts":"2.0","event_ts":"2.0"}}`
+	res := h.processSlackEvent(ctx, []byte(msg))
+	if res.Outcome != "ingested" || !res.DryRun {
+		t.Fatalf("unexpected slack result: %+v", res)
+	}
+	if msgs, _ := st.ListMessagesByPRURL(ctx, prURL); len(msgs) != 0 {
+		t.Fatalf("dry run must not track messages, got %+v", msgs)
+	}
+
+	if err := st.InsertPRMessage(ctx, prURL, "", "C1", "1.0"); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	body := `{"action":"closed","pull_request":{"html_url":"` + prURL + `","merged":true}}`
+	res = h.processGitHubEvent(ctx, "pull_request", []byte(body))
+	if res.Outcome != "merged" || !res.DryRun || len(res.Reactions) != 1 || res.Reactions[0].Error != "" {
+		t.Fatalf("unexpected github result: %+v", res)
+	}
+	if msgs, _ := st.ListMessagesByPRURL(ctx, prURL); len(msgs) != 1 {
+		t.Fatalf("dry run must keep the mappings, got %+v", msgs)
+	}
+	if failed, _ := st.CountFailedReactions(ctx); failed != 0 {
+		t.Fatalf("dry run must not record failed reactions, got %d", failed)
+	}
+}
+
+func TestProcessDryRunLeavesEventMetrics(t *testing.T) {
+	h := newTestHandlers(t, config.Config{})
+	ctx := withDryRun(t.Context())
+	slackIngested := metrics.SlackEvents.WithLabelValues("message", "ingested")
+	githubUntracked := metrics.GitHubEvents.WithLabelValues("pull_request", "untracked")
+	before := []float64{testutil.ToFloat64(slackIngested), testutil.ToFloat64(githubUntracked), testutil.ToFloat64(metrics.PRURLsIngested)}
+
+	msg := `{"type":"event_callback","event":{"type":"message","text":"<https://github.com/o/r/pull/1>","channel":"C1","ts":"1.0","event_ts":"1.0"}}`
+	if res := h.processSlackEvent(ctx, []byte(msg)); res.Outcome != "ingested" {
+		t.Fatalf("unexpected slack result: %+v", res)
+	}
+	body := `{"action":"closed","pull_request":{"html_url":"https://github.com/o/r/pull/2","merged":true}}`
+	if res := h.processGitHubEvent(ctx, "pull_request", []byte(body)); res.Outcome != "untracked" {
+		t.Fatalf("unexpected github result: %+v", res)
+	}
+
+	after := []float64{testutil.ToFloat64(slackIngested), testutil.ToFloat64(githubUntracked), testutil.ToFloat64(metrics.PRURLsIngested)}
+	if !slices.Equal(before, after) {
+		t.Fatalf("dry runs must not count as live events: %v -> %v", before, after)
+	}
+}
@@ -0,0 +1,42 @@
+package http
+
+import (
+	"context"
+
+	"github.com/adamantal/prmoji/internal/github"
+)
+
+// applyPRState reacts to a newly posted PR link with what already happened to the PR, so a
+// link to an approved or merged PR doesn't stay bare until the next webhook. Failing to
+// read the state is logged and otherwise ignored; the webhooks still apply from here on.
+func (h *Handlers) applyPRState(ctx context.Context, prURL, teamID, channel, ts string) []Reaction {
+	pr, ok := github.ParsePRURL(prURL)
+	if !ok {
+		return nil
+	}
+	state, err := h.GitHub.PullRequestState(ctx, pr)
+	if err != nil {
+		h.Log.WarnContext(ctx, "fetch pr state failed", "err", err, "pr_url", prURL)
+		return nil
+	}
+
+	// A finished PR gets no more webhooks worth waiting for. It is forgotten before its
+	// reactions are delivered, since forgetting it drops the reactions queued on it.
+	finished := state.Merged || state.Closed
+	if finished {
+		h.deleteMappings(ctx, prURL)
+	}
+
+	var out []Reaction
+	for _, action := range state.Actions() {
+		emoji := h.settings().Emojis.For(action)
+		if !finished {
+			h.recordReaction(ctx, prURL, emoji)
+		}
+		out = append(out, h.deliver(ctx, prURL, teamID, channel, ts, emoji))
+	}
+	if len(out) > 0 {
+		h.Log.InfoContext(ctx, "applied current pr state", "pr_url", prURL, "channel", channel, "reactions", len(out))
+	}
+	return out
+}
@@ -0,0 +1,78 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/adamantal/prmoji/internal/config"
+	"github.com/adamantal/prmoji/internal/github"
+)
+
+func TestProcessSlackEventAppliesPRState(t *testing.T) {
+	gh := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/o/r/pulls/1":
+			_, _ = w.Write([]byte(`{"state":"open","merged":false}`))
+		case "/repos/o/r/pulls/1/reviews":
+			_, _ = w.Write([]byte(`[{"state":"APPROVED","user":{"login":"alice"}}]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(gh.Close)
+
+	h := newTestHandlers(t, config.Config{})
+	h.GitHub = github.NewClient("ghp-test", gh.URL)
+
+	body := []byte(`{"type":"event_callback","event":{"type":"message","text":"<https://github.com/o/r/pull/1>","channel":"C1","ts":"1.0","event_ts":"1.0"}}`)
+	res := h.processSlackEvent(withDryRun(context.Background()), body)
+	if res.Outcome != "ingested" || len(res.Reactions) != 1 || res.Reactions[0].Emoji != "white_check_mark" {
+		t.Fatalf("expected an approval reaction got %+v", res)
+	}
+}
+
+func TestProcessSlackEventAppliesPRStateLive(t *testing.T) {
+	gh := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/o/r/pulls/1":
+			_, _ = w.Write([]byte(`{"state":"open","merged":false}`))
+		case "/repos/o/r/pulls/1/reviews":
+			_, _ = w.Write([]byte(`[{"state":"APPROVED","user":{"login":"alice"}}]`))
+		case "/repos/o/r/pulls/2":
+			_, _ = w.Write([]byte(`{"state":"closed","merged":true}`))
+		case "/repos/o/r/pulls/2/reviews":
+			_, _ = w.Write([]byte(`[]`))
+		default:
+			http.Error(w, `{"message":"Server Error"}`, http.StatusInternalServerError)
+		}
+	}))
+	t.Cleanup(gh.Close)
+
+	h := newTestHandlers(t, config.Config{})
+	h.GitHub = github.NewClient("ghp-test", gh.URL)
+	fake := newFakeSlack(t, h)
+	ctx := t.Context()
+
+	const approved, merged, failing = "https://github.com/o/r/pull/1", "https://github.com/o/r/pull/2", "https://github.com/o/r/pull/3"
+	body := []byte(`{"type":"event_callback","event":{"type":"message","text":"<` + approved + `> <` + merged + `> <` + failing + `>","channel":"C1","ts":"1.0","event_ts":"1.0"}}`)
+	res := h.processSlackEvent(ctx, body)
+	if res.Outcome != "ingested" || len(res.Reactions) != 2 {
+		t.Fatalf("expected two reactions got %+v", res)
+	}
+
+	mergedEmoji := h.settings().Emojis.For(github.ActionMerged)
+	calls := fake.Calls()
+	if len(calls) != 2 || calls[0] != "reactions.add C1 1.0 white_check_mark" || calls[1] != "reactions.add C1 1.0 "+mergedEmoji {
+		t.Fatalf("unexpected slack calls: %v", calls)
+	}
+	for prURL, want := range map[string]int{approved: 1, merged: 0, failing: 1} {
+		if msgs, err := h.Store.ListMessagesByPRURL(ctx, prURL); err != nil || len(msgs) != want {
+			t.Fatalf("%s: expected %d messages got %d (%v)", prURL, want, len(msgs), err)
+		}
+	}
+	if n, err := h.Store.CountFailedReactions(ctx); err != nil || n != 0 {
+		t.Fatalf("a GitHub error must not fail any reaction, got %d (%v)", n, err)
+	}
+}
@@ -0,0 +1,91 @@
+package http
+
+import (
+	"context"
+	"time"
+
+	"github.com/adamantal/prmoji/internal/github"
+	"github.com/adamantal/prmoji/internal/metrics"
+	"github.com/adamantal/prmoji/internal/store"
+)
+
+// queueBatchSize bounds how many due reactions one FlushQueuedReactions adds.
+const queueBatchSize = 100
+
+// supersedes maps a review decision to the one it replaces while both are still queued, so
+// a PR approved right after changes were requested only shows the approval.
+var supersedes = map[github.Action]github.Action{
+	github.ActionApproved:         github.ActionChangesRequested,
+	github.ActionChangesRequested: github.ActionApproved,
+}
+
+// QueuesReactions reports whether reactions may be held back, i.e. whether the flush loop has to run.
+func (h *Handlers) QueuesReactions() bool {
+	cfg := h.settings()
+	return cfg.ReactionDebounce > 0 || len(cfg.QuietHours) > 0
+}
+
+// deliver adds a reaction, or queues it when the debounce window or the channel's quiet hours
+// hold it back.
+func (h *Handlers) deliver(ctx context.Context, prURL, teamID, channel, ts, emoji string) Reaction {
+	cfg, now := h.settings(), time.Now()
+	due, reason := now.Add(cfg.ReactionDebounce), "debounce"
+	if end, ok := cfg.QuietHours.Until(channel, now); ok && end.After(due) {
+		due, reason = end, "quiet_hours"
+	}
+	if !due.After(now) {
+		return h.react(ctx, prURL, teamID, channel, ts, emoji)
+	}
+
+	if isDryRun(ctx) {
+		h.skipped(ctx, "reaction", "queue reaction", "pr_url", prURL, "channel", channel, "ts", ts, "emoji", emoji, "due_at", due, "reason", reason)
+		return Reaction{Channel: channel, TS: ts, Emoji: emoji, DueAt: due.UTC()}
+	}
+	qr := store.QueuedReaction{PRURL: prURL, TeamID: teamID, Channel: channel, TS: ts, Emoji: emoji, DueAt: due}
+	if err := h.Store.QueueReaction(ctx, qr); err != nil {
+		h.Log.ErrorContext(ctx, "queue reaction failed, adding it now", "err", err, "pr_url", prURL, "channel", channel, "ts", ts, "emoji", emoji)
+		return h.react(ctx, prURL, teamID, channel, ts, emoji)
+	}
+	metrics.ReactionsQueued.WithLabelValues(reason).Inc()
+	h.Log.DebugContext(ctx, "queued reaction", "pr_url", prURL, "channel", channel, "ts", ts, "emoji", emoji, "due_at", due, "reason", reason)
+	return Reaction{Channel: channel, TS: ts, Emoji: emoji, DueAt: due.UTC()}
+}
+
+// dropSuperseded removes the queued reactions that action makes stale.
+func (h *Handlers) dropSuperseded(ctx context.Context, prURL string, action github.Action) {
+	old, ok := supersedes[action]
+	if !ok || !h.QueuesReactions() {
+		return
+	}
+	if isDryRun(ctx) {
+		h.skipped(ctx, "store_write", "drop superseded queued reactions", "pr_url", prURL, "action", string(action))
+		return
+	}
+	n, err := h.Store.DeleteQueuedReactionsByPRURL(ctx, prURL, h.settings().Emojis.For(old))
+	if err != nil {
+		h.Log.ErrorContext(ctx, "drop superseded reactions failed", "err", err, "pr_url", prURL)
+		return
+	}
+	if n > 0 {
+		h.Log.InfoContext(ctx, "dropped superseded queued reactions", "pr_url", prURL, "action", string(action), "dropped", n)
+	}
+}
+
+// FlushQueuedReactions adds the queued reactions that are due. Reactions Slack rejects move
+// to the failed reactions for the retry loop.
+func (h *Handlers) FlushQueuedReactions(ctx context.Context) (int, error) {
+	due, err := h.Store.ListDueReactions(ctx, time.Now(), queueBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	for i, qr := range due {
+		h.react(ctx, qr.PRURL, qr.TeamID, qr.Channel, qr.TS, qr.Emoji)
+		if _, err := h.Store.DeleteQueuedReaction(ctx, qr.ID); err != nil {
+			return i + 1, err
+		}
+	}
+	if len(due) > 0 {
+		h.Log.InfoContext(ctx, "added queued reactions", "count", len(due))
+	}
+	return len(due), nil
+}
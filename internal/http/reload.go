@@ -0,0 +1,39 @@
+package http
+
+import (
+	"github.com/adamantal/prmoji/internal/cleanup"
+	"github.com/adamantal/prmoji/internal/config"
+	"github.com/adamantal/prmoji/internal/routing"
+)
+
+// settings returns the configuration in effect: Cfg, with the settings a config file reload
+// replaced since startup.
+func (h *Handlers) settings() config.Config {
+	if cfg := h.reloaded.Load(); cfg != nil {
+		return *cfg
+	}
+	return h.Cfg
+}
+
+// Reload applies the settings of cfg that may change while prmoji runs; see config.Config.Reloaded.
+func (h *Handlers) Reload(cfg config.Config) {
+	next := h.settings().Reloaded(cfg)
+	h.reloaded.Store(&next)
+	h.Log.Info("configuration reloaded", "file", cfg.ConfigFile)
+}
+
+// ChannelRules returns the channel rules in effect, e.g. for a backfill.
+func (h *Handlers) ChannelRules() routing.Rules {
+	return h.settings().ChannelRules
+}
+
+// CleanupOptions returns the configured retention settings.
+func (h *Handlers) CleanupOptions() cleanup.Options {
+	cfg := h.settings()
+	return cleanup.Options{
+		RetentionDays: cfg.RetentionDays,
+		Policy:        cfg.RetentionPolicy,
+		ChannelDays:   cfg.RetentionChannelDays,
+		ArchiveDays:   cfg.ArchiveDays,
+	}
+}
@@ -0,0 +1,86 @@
+package http
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/adamantal/prmoji/internal/github"
+)
+
+// channelNameTTL is how long a looked up channel name is reused; renames are rare.
+const channelNameTTL = time.Hour
+
+type cachedName struct {
+	name      string
+	fetchedAt time.Time
+}
+
+// channelNameCache remembers channel names for CHANNEL_RULES that match by name. Its zero value is ready to use.
+type channelNameCache struct {
+	mu    sync.Mutex
+	names map[string]cachedName
+}
+
+func (c *channelNameCache) get(channel string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n, ok := c.names[channel]
+	if !ok || time.Since(n.fetchedAt) > channelNameTTL {
+		return "", false
+	}
+	return n.name, true
+}
+
+func (c *channelNameCache) put(channel, name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.names == nil {
+		c.names = map[string]cachedName{}
+	}
+	c.names[channel] = cachedName{name: name, fetchedAt: time.Now()}
+}
+
+// routedURLs drops the PR URLs that CHANNEL_RULES don't allow in the channel.
+func (h *Handlers) routedURLs(ctx context.Context, teamID, channel string, urls []string) []string {
+	rules := h.settings().ChannelRules
+	if len(rules) == 0 {
+		return urls
+	}
+	var name string
+	if rules.UsesNames() {
+		name = h.channelName(ctx, teamID, channel)
+	}
+	var out []string
+	for _, u := range urls {
+		pr, ok := github.ParsePRURL(u)
+		if !ok {
+			continue
+		}
+		if !rules.Allows(channel, name, pr.Owner+"/"+pr.Repo) {
+			h.Log.DebugContext(ctx, "pr url not routed to channel", "pr_url", u, "channel", channel, "channel_name", name)
+			continue
+		}
+		out = append(out, u)
+	}
+	return out
+}
+
+// channelName looks a channel's name up, returning "" when Slack can't tell; rules by name then don't match.
+func (h *Handlers) channelName(ctx context.Context, teamID, channel string) string {
+	if name, ok := h.channelNames.get(channel); ok {
+		return name
+	}
+	sc, err := h.slackFor(ctx, teamID)
+	if err != nil {
+		h.Log.WarnContext(ctx, "look up channel name failed", "err", err, "channel", channel)
+		return ""
+	}
+	name, err := sc.ChannelName(ctx, channel)
+	if err != nil {
+		h.Log.WarnContext(ctx, "look up channel name failed", "err", err, "channel", channel)
+		return ""
+	}
+	h.channelNames.put(channel, name)
+	return name
+}
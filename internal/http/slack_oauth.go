@@ -0,0 +1,131 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"time"
+
+	"github.com/adamantal/prmoji/internal/slack"
+	"github.com/adamantal/prmoji/internal/store"
+)
+
+// slackFor returns the Slack client for a workspace: the bot token stored when it was installed
+// through OAuth, or SLACK_TOKEN for events without a team and workspaces never installed that way.
+func (h *Handlers) slackFor(ctx context.Context, teamID string) (*slack.Client, error) {
+	if teamID == "" || h.Cfg.SlackClientID == "" {
+		return h.Slack, nil
+	}
+	inst, err := h.Store.GetSlackInstallation(ctx, teamID)
+	if errors.Is(err, store.ErrNotFound) {
+		if h.Cfg.SlackToken == "" {
+			return nil, fmt.Errorf("slack workspace %s is not installed", teamID)
+		}
+		return h.Slack, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return h.Slack.WithToken(inst.BotToken), nil
+}
+
+// processAppUninstalled forgets the bot token of a workspace prmoji was removed from, and the
+// messages and reactions it can no longer act on there.
+func (h *Handlers) processAppUninstalled(ctx context.Context, env slack.EventEnvelope, res Result) Result {
+	res.Outcome = "uninstalled"
+	countSlackEvent(ctx, "app_uninstalled", res.Outcome)
+	if res.DryRun {
+		h.skipped(ctx, "store_write", "delete slack installation", "team_id", env.TeamID)
+		return res
+	}
+	found, err := h.Store.DeleteSlackInstallation(ctx, env.TeamID)
+	if err != nil {
+		h.Log.ErrorContext(ctx, "delete slack installation failed", "err", err, "team_id", env.TeamID)
+		return res
+	}
+	n, err := h.Store.DeleteTeam(ctx, env.TeamID)
+	if err != nil {
+		h.Log.ErrorContext(ctx, "delete slack workspace messages failed", "err", err, "team_id", env.TeamID)
+	}
+	h.Log.InfoContext(ctx, "slack workspace uninstalled", "team_id", env.TeamID, "was_installed", found, "messages", n)
+	return res
+}
+
+// oauthNonceCookie holds the nonce that ties an install's state to the browser that started it,
+// so that a callback link can't be used from another browser.
+const oauthNonceCookie = "prmoji_oauth_nonce"
+
+// handleSlackInstall sends the user to Slack to add prmoji to a workspace.
+func (h *Handlers) handleSlackInstall(w http.ResponseWriter, r *http.Request) {
+	nonce := slack.NewOAuthNonce()
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthNonceCookie,
+		Value:    nonce,
+		Path:     "/slack/",
+		MaxAge:   int(slack.OAuthStateMaxAge / time.Second),
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		// Lax still sends the cookie on the top-level redirect back from Slack.
+		SameSite: http.SameSiteLaxMode,
+	})
+	state := slack.OAuthState(h.Cfg.SlackClientSecret, nonce, time.Now())
+	http.Redirect(w, r, slack.AuthorizeURL(h.Cfg.SlackClientID, h.Cfg.SlackRedirectURL, state), http.StatusFound)
+}
+
+// handleSlackOAuthCallback completes an install: Slack redirects here with a code that is
+// exchanged for the workspace's bot token.
+func (h *Handlers) handleSlackOAuthCallback(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		h.Log.InfoContext(ctx, "slack install declined", "error", e)
+		writeInstallPage(w, http.StatusBadRequest, "prmoji was not installed: "+e)
+		return
+	}
+	var nonce string
+	if c, err := r.Cookie(oauthNonceCookie); err == nil {
+		nonce = c.Value
+	}
+	if err := slack.VerifyOAuthState(h.Cfg.SlackClientSecret, nonce, q.Get("state"), time.Now()); err != nil {
+		h.Log.WarnContext(ctx, "rejected slack oauth callback", "err", err, "has_cookie", nonce != "")
+		writeInstallPage(w, http.StatusBadRequest, "This install link has expired or was started in another browser, please start again.")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oauthNonceCookie, Path: "/slack/", MaxAge: -1, HttpOnly: true})
+	code := q.Get("code")
+	if code == "" {
+		writeInstallPage(w, http.StatusBadRequest, "Missing code.")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	access, err := h.Slack.WithToken("").OAuthV2Access(ctx, h.Cfg.SlackClientID, h.Cfg.SlackClientSecret, code, h.Cfg.SlackRedirectURL)
+	if err != nil {
+		h.Log.ErrorContext(ctx, "slack oauth exchange failed", "err", err)
+		writeInstallPage(w, http.StatusBadGateway, "Slack did not complete the install, please try again.")
+		return
+	}
+	inst := store.SlackInstallation{
+		TeamID:    access.Team.ID,
+		TeamName:  access.Team.Name,
+		BotUserID: access.BotUserID,
+		BotToken:  access.AccessToken,
+		Scope:     access.Scope,
+	}
+	if err := h.Store.SaveSlackInstallation(ctx, inst); err != nil {
+		h.Log.ErrorContext(ctx, "save slack installation failed", "err", err, "team_id", inst.TeamID)
+		writeInstallPage(w, http.StatusInternalServerError, "Could not save the install, please try again.")
+		return
+	}
+	h.Log.InfoContext(ctx, "slack workspace installed", "team_id", inst.TeamID, "team", inst.TeamName, "bot_user_id", inst.BotUserID)
+	writeInstallPage(w, http.StatusOK, "prmoji was installed to "+inst.TeamName+". Invite it to the channels where PRs are posted.")
+}
+
+func writeInstallPage(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = fmt.Fprintf(w, "<!doctype html><title>prmoji</title><p>%s</p>\n", html.EscapeString(msg))
+}
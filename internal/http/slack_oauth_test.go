@@ -0,0 +1,107 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/adamantal/prmoji/internal/config"
+	"github.com/adamantal/prmoji/internal/slack"
+	"github.com/adamantal/prmoji/internal/store"
+)
+
+func TestSlackOAuthInstall(t *testing.T) {
+	h := newTestHandlers(t, config.Config{SlackClientID: "cid", SlackClientSecret: "shh", SlackSigningSecret: "secret"})
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"ok":true,"access_token":"xoxb-t2","scope":"reactions:write","bot_user_id":"U2","team":{"id":"T2","name":"Beta"}}`))
+	}))
+	t.Cleanup(api.Close)
+	sc, err := slack.NewClientWithOptions("", slack.Options{BaseURL: api.URL})
+	if err != nil {
+		t.Fatalf("new slack client: %v", err)
+	}
+	h.Slack = sc
+	mux := http.NewServeMux()
+	h.Register(mux)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	noRedirect := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+
+	resp, err := noRedirect.Get(srv.URL + "/slack/install")
+	if err != nil {
+		t.Fatalf("install: %v", err)
+	}
+	_ = resp.Body.Close()
+	loc, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || resp.StatusCode != http.StatusFound {
+		t.Fatalf("expected a redirect to Slack got %d %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	var nonce *http.Cookie
+	for _, c := range resp.Cookies() {
+		if c.Name == oauthNonceCookie {
+			nonce = c
+		}
+	}
+	if nonce == nil || !nonce.HttpOnly {
+		t.Fatalf("expected an HttpOnly nonce cookie, got %v", resp.Cookies())
+	}
+
+	callback := srv.URL + "/slack/oauth/callback?code=abc&state=" + url.QueryEscape(loc.Query().Get("state"))
+	callbackWith := func(c *http.Cookie) int {
+		t.Helper()
+		req, err := http.NewRequest("GET", callback, nil)
+		if err != nil {
+			t.Fatalf("new request: %v", err)
+		}
+		if c != nil {
+			req.AddCookie(c)
+		}
+		resp, err := noRedirect.Do(req)
+		if err != nil {
+			t.Fatalf("callback: %v", err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := callbackWith(nil); code != http.StatusBadRequest {
+		t.Fatalf("expected a callback without the cookie to be rejected, got %d", code)
+	}
+	if code := callbackWith(&http.Cookie{Name: oauthNonceCookie, Value: slack.NewOAuthNonce()}); code != http.StatusBadRequest {
+		t.Fatalf("expected a callback from another browser to be rejected, got %d", code)
+	}
+	if _, err := h.Store.GetSlackInstallation(t.Context(), "T2"); err == nil {
+		t.Fatalf("rejected callbacks must not install")
+	}
+	if code := callbackWith(nonce); code != http.StatusOK {
+		t.Fatalf("expected the install to complete, got %d", code)
+	}
+	if inst, err := h.Store.GetSlackInstallation(t.Context(), "T2"); err != nil || inst.BotToken != "xoxb-t2" {
+		t.Fatalf("expected T2 to be installed, got %+v (%v)", inst, err)
+	}
+}
+
+func TestProcessAppUninstalled(t *testing.T) {
+	h := newTestHandlers(t, config.Config{SlackClientID: "cid"})
+	st := h.Store
+	ctx := t.Context()
+	if err := st.SaveSlackInstallation(ctx, store.SlackInstallation{TeamID: "T2", BotToken: "xoxb-t2"}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if err := st.InsertPRMessage(ctx, "https://github.com/o/r/pull/1", "T2", "C1", "1.0"); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	res := h.processSlackEvent(ctx, []byte(`{"type":"event_callback","team_id":"T2","event":{"type":"app_uninstalled"}}`))
+	if res.Outcome != "uninstalled" {
+		t.Fatalf("expected uninstalled got %+v", res)
+	}
+	if _, err := st.GetSlackInstallation(ctx, "T2"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("expected the installation to be deleted, got %v", err)
+	}
+	if n, err := st.CountTrackedPRs(ctx); err != nil || n != 0 {
+		t.Fatalf("expected the workspace's messages to be deleted, got %d (%v)", n, err)
+	}
+}
@@ -1,12 +1,13 @@
 package log
 
 import (
+	"context"
 	"log/slog"
 	"os"
 	"strings"
 )
 
-func New(level string) *slog.Logger {
+func New(level, format string) *slog.Logger {
 	lvl := slog.LevelInfo
 	switch strings.ToLower(strings.TrimSpace(level)) {
 	case "debug":
@@ -19,6 +20,45 @@ func New(level string) *slog.Logger {
 		lvl = slog.LevelInfo
 	}
 
-	h := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: lvl})
-	return slog.New(h)
+	opts := &slog.HandlerOptions{Level: lvl}
+	var h slog.Handler
+	if strings.EqualFold(strings.TrimSpace(format), "json") {
+		h = slog.NewJSONHandler(os.Stdout, opts)
+	} else {
+		h = slog.NewTextHandler(os.Stdout, opts)
+	}
+	return slog.New(contextHandler{h})
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context whose log records carry id as request_id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID stored in ctx, if any.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// contextHandler adds the request ID from the context to records logged with the *Context methods.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
 }
@@ -0,0 +1,27 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestContextHandlerAddsRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(contextHandler{slog.NewJSONHandler(&buf, nil)}).With("component", "test")
+
+	logger.InfoContext(WithRequestID(context.Background(), "delivery-1"), "hello")
+
+	var rec map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("decode log record: %v", err)
+	}
+	if rec["request_id"] != "delivery-1" {
+		t.Fatalf("expected request_id delivery-1 got %v", rec["request_id"])
+	}
+	if rec["component"] != "test" {
+		t.Fatalf("expected attrs from With to be kept got %v", rec)
+	}
+}
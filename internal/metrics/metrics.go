@@ -0,0 +1,142 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "prmoji"
+
+var (
+	SlackEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "slack_events_total",
+		Help:      "Slack events received, by event type and processing outcome.",
+	}, []string{"type", "outcome"})
+
+	GitHubEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "github_events_total",
+		Help:      "GitHub webhook events received, by event type and classification outcome.",
+	}, []string{"event", "outcome"})
+
+	PRURLsIngested = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "pr_urls_ingested_total",
+		Help:      "PR URLs extracted from Slack messages and stored.",
+	})
+
+	Reactions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reactions_total",
+		Help:      "Slack reactions.add calls, by emoji and result (ok or Slack error code).",
+	}, []string{"emoji", "result"})
+
+	StoreQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "store_query_duration_seconds",
+		Help:      "SQLite store operation latency, by operation.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"op"})
+
+	CleanupRowsDeleted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cleanup_rows_deleted_total",
+		Help:      "Rows deleted by retention cleanup, by table.",
+	}, []string{"table"})
+
+	ReactionRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reaction_retries_total",
+		Help:      "Retries of previously failed reactions, by result (ok, failed or gave_up).",
+	}, []string{"result"})
+
+	ReactionsQueued = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reactions_queued_total",
+		Help:      "Reactions held back instead of added right away, by reason (debounce or quiet_hours).",
+	}, []string{"reason"})
+
+	DryRunActions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dry_run_actions_total",
+		Help:      "Slack calls and store writes skipped in dry-run mode, by kind (reaction, message or store_write).",
+	}, []string{"kind"})
+
+	SocketModeConnected = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "socket_mode_connected",
+		Help:      "1 while a Slack Socket Mode connection is established.",
+	})
+
+	AsyncInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "async_inflight",
+		Help:      "Asynchronous event processing goroutines currently running, by source.",
+	}, []string{"source"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		SlackEvents,
+		GitHubEvents,
+		PRURLsIngested,
+		Reactions,
+		StoreQueryDuration,
+		CleanupRowsDeleted,
+		ReactionRetries,
+		ReactionsQueued,
+		DryRunActions,
+		SocketModeConnected,
+		AsyncInFlight,
+	)
+}
+
+// RegisterTrackedPRs exposes the number of tracked PRs, computed by count at scrape time.
+func RegisterTrackedPRs(count func() (int64, error)) {
+	prometheus.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "tracked_prs",
+		Help:      "Distinct PR URLs currently tracked.",
+	}, func() float64 {
+		n, err := count()
+		if err != nil {
+			return -1
+		}
+		return float64(n)
+	}))
+}
+
+// RegisterQueuedReactions exposes the number of reactions waiting to be added, computed by count at scrape time.
+func RegisterQueuedReactions(count func() (int64, error)) {
+	prometheus.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "queued_reactions",
+		Help:      "Reactions held back by debouncing or quiet hours.",
+	}, func() float64 {
+		n, err := count()
+		if err != nil {
+			return -1
+		}
+		return float64(n)
+	}))
+}
+
+// ObserveStoreQuery records the latency of a store operation started at start.
+func ObserveStoreQuery(op string, start time.Time) {
+	StoreQueryDuration.WithLabelValues(op).Observe(time.Since(start).Seconds())
+}
+
+// TrackInFlight marks one async goroutine for source as running; call the returned func when it ends.
+func TrackInFlight(source string) func() {
+	g := AsyncInFlight.WithLabelValues(source)
+	g.Inc()
+	return g.Dec
+}
+
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,106 @@
+package quiet
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	// Slim container images don't ship a zoneinfo database.
+	_ "time/tzdata"
+)
+
+// Window is a daily period in which reactions in the channels it matches are held back.
+type Window struct {
+	// Channel is a glob over channel IDs.
+	Channel string
+	// Start and End are minutes after midnight in Location; a window with End before Start
+	// runs past midnight.
+	Start, End int
+	Location   *time.Location
+}
+
+// Hours are evaluated in order and the first window matching a channel decides.
+type Hours []Window
+
+// Parse reads windows written as `CHANNEL=HH:MM-HH:MM[@ZONE];...`, e.g.
+// `C0DEPS=18:00-09:00@America/New_York;*=22:00-07:00@Europe/Budapest`. ZONE is an IANA time
+// zone and defaults to UTC.
+func Parse(s string) (Hours, error) {
+	var hours Hours
+	for _, raw := range strings.Split(s, ";") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		channel, span, ok := strings.Cut(raw, "=")
+		channel = strings.ToLower(strings.TrimSpace(channel))
+		if !ok || channel == "" {
+			return nil, fmt.Errorf("invalid quiet hours %q: expected CHANNEL=HH:MM-HH:MM", raw)
+		}
+		if _, err := path.Match(channel, ""); err != nil {
+			return nil, fmt.Errorf("invalid quiet hours %q: bad channel pattern", raw)
+		}
+		span, zone, hasZone := strings.Cut(span, "@")
+		w := Window{Channel: channel, Location: time.UTC}
+		if hasZone {
+			loc, err := time.LoadLocation(strings.TrimSpace(zone))
+			if err != nil {
+				return nil, fmt.Errorf("invalid quiet hours %q: %w", raw, err)
+			}
+			w.Location = loc
+		}
+		start, end, ok := strings.Cut(span, "-")
+		var err error
+		if w.Start, err = parseClock(start); err == nil && ok {
+			w.End, err = parseClock(end)
+		}
+		if !ok || err != nil || w.Start == w.End {
+			return nil, fmt.Errorf("invalid quiet hours %q: expected HH:MM-HH:MM", raw)
+		}
+		hours = append(hours, w)
+	}
+	return hours, nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Until returns when the quiet hours covering now end for the channel, or false when
+// reactions may be added right away.
+func (hs Hours) Until(channel string, now time.Time) (time.Time, bool) {
+	channel = strings.ToLower(channel)
+	for _, w := range hs {
+		if ok, _ := path.Match(w.Channel, channel); ok {
+			return w.until(now)
+		}
+	}
+	return time.Time{}, false
+}
+
+func (w Window) until(now time.Time) (time.Time, bool) {
+	local := now.In(w.Location)
+	minute := local.Hour()*60 + local.Minute()
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, w.Location)
+	switch {
+	case w.Start < w.End && minute >= w.Start && minute < w.End:
+		return atMinute(midnight, w.End), true
+	case w.Start > w.End && minute >= w.Start:
+		return atMinute(midnight.AddDate(0, 0, 1), w.End), true
+	case w.Start > w.End && minute < w.End:
+		return atMinute(midnight, w.End), true
+	default:
+		return time.Time{}, false
+	}
+}
+
+// atMinute goes through the wall clock rather than adding a duration, so DST changes
+// don't shift the end of the window.
+func atMinute(day time.Time, minute int) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), minute/60, minute%60, 0, 0, day.Location())
+}
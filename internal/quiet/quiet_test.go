@@ -0,0 +1,54 @@
+package quiet
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	hours, err := Parse(" C0DEPS = 18:00-09:30 @ America/New_York ;; *=12:00-13:00 ")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(hours) != 2 || hours[0].Channel != "c0deps" || hours[0].Start != 18*60 || hours[0].End != 9*60+30 || hours[0].Location.String() != "America/New_York" {
+		t.Fatalf("unexpected window: %+v", hours[0])
+	}
+	if hours[1].Location != time.UTC {
+		t.Fatalf("expected UTC by default got %v", hours[1].Location)
+	}
+
+	for _, bad := range []string{"C0DEPS", "=18:00-09:00", "C0DEPS=18:00", "C0DEPS=18-09", "C0DEPS=25:00-09:00", "C0DEPS=09:00-09:00", "C0DEPS=18:00-09:00@Mars/Base", "[C=18:00-09:00"} {
+		if _, err := Parse(bad); err == nil {
+			t.Fatalf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestUntil(t *testing.T) {
+	hours, err := Parse("C0DEPS=22:00-07:00@Europe/Budapest;C1=12:00-13:00")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	budapest, _ := time.LoadLocation("Europe/Budapest")
+	tests := []struct {
+		name    string
+		channel string
+		now     time.Time
+		want    time.Time
+	}{
+		{"before midnight", "C0DEPS", time.Date(2026, 3, 10, 23, 15, 0, 0, budapest), time.Date(2026, 3, 11, 7, 0, 0, 0, budapest)},
+		{"after midnight", "c0deps", time.Date(2026, 3, 11, 3, 0, 0, 0, budapest), time.Date(2026, 3, 11, 7, 0, 0, 0, budapest)},
+		{"across a DST change", "C0DEPS", time.Date(2026, 3, 28, 23, 0, 0, 0, budapest), time.Date(2026, 3, 29, 7, 0, 0, 0, budapest)},
+		{"daytime", "C0DEPS", time.Date(2026, 3, 11, 7, 0, 0, 0, budapest), time.Time{}},
+		{"same-day window", "C1", time.Date(2026, 3, 11, 12, 30, 0, 0, time.UTC), time.Date(2026, 3, 11, 13, 0, 0, 0, time.UTC)},
+		{"no window", "C2", time.Date(2026, 3, 11, 12, 30, 0, 0, time.UTC), time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := hours.Until(tt.channel, tt.now)
+			if ok != !tt.want.IsZero() || !got.Equal(tt.want) {
+				t.Fatalf("expected %v got %v (%v)", tt.want, got, ok)
+			}
+		})
+	}
+}
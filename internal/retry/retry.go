@@ -0,0 +1,87 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/adamantal/prmoji/internal/metrics"
+	"github.com/adamantal/prmoji/internal/slack"
+	"github.com/adamantal/prmoji/internal/store"
+)
+
+// DefaultBatchSize bounds how many failed reactions one Run retries.
+const DefaultBatchSize = 100
+
+type Options struct {
+	// MaxAttempts is the attempt count after which a failed reaction is no longer retried automatically.
+	MaxAttempts int
+	// BatchSize limits the reactions retried per run; 0 means DefaultBatchSize.
+	BatchSize int
+}
+
+type Result struct {
+	Retried    int   `json:"retried"`
+	Succeeded  int   `json:"succeeded"`
+	Failed     int   `json:"failed"`
+	GaveUp     int   `json:"gave_up"`
+	DurationMS int64 `json:"duration_ms"`
+}
+
+// Run retries failed reactions that have fewer than opts.MaxAttempts attempts, least recently
+// tried first. Reactions that reach the limit stay in the store for manual inspection.
+func Run(ctx context.Context, st *store.SQLiteStore, clientFor slack.ClientFor, opts Options) (Result, error) {
+	start := time.Now()
+	batch := opts.BatchSize
+	if batch <= 0 {
+		batch = DefaultBatchSize
+	}
+	frs, err := st.ListRetryableReactions(ctx, opts.MaxAttempts, batch)
+	if err != nil {
+		return Result{}, err
+	}
+
+	var res Result
+	for _, fr := range frs {
+		res.Retried++
+		err := Attempt(ctx, st, clientFor, fr)
+		switch {
+		case err == nil:
+			res.Succeeded++
+			metrics.ReactionRetries.WithLabelValues("ok").Inc()
+		case fr.Attempts+1 >= opts.MaxAttempts:
+			res.GaveUp++
+			metrics.ReactionRetries.WithLabelValues("gave_up").Inc()
+			slog.WarnContext(ctx, "giving up on reaction", "id", fr.ID, "pr_url", fr.PRURL, "channel", fr.Channel, "ts", fr.TS, "emoji", fr.Emoji, "attempts", fr.Attempts+1, "err", err)
+		default:
+			res.Failed++
+			metrics.ReactionRetries.WithLabelValues("failed").Inc()
+		}
+	}
+	res.DurationMS = time.Since(start).Milliseconds()
+	if res.Retried > 0 {
+		slog.InfoContext(ctx, "retried failed reactions", "retried", res.Retried, "succeeded", res.Succeeded, "failed", res.Failed, "gave_up", res.GaveUp)
+	}
+	return res, nil
+}
+
+// Attempt adds fr's reaction once more. On success fr is removed from the store; otherwise the
+// new error is recorded against it and returned.
+func Attempt(ctx context.Context, st *store.SQLiteStore, clientFor slack.ClientFor, fr store.FailedReaction) error {
+	sc, err := clientFor(ctx, fr.TeamID)
+	if err == nil {
+		err = sc.AddReaction(ctx, fr.Channel, fr.TS, fr.Emoji)
+	}
+	if err != nil {
+		fr.Error = err.Error()
+		if recErr := st.RecordFailedReaction(ctx, fr); recErr != nil {
+			return errors.Join(err, recErr)
+		}
+		return err
+	}
+	if _, err := st.DeleteFailedReaction(ctx, fr.ID); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/adamantal/prmoji/internal/slack"
+	"github.com/adamantal/prmoji/internal/store"
+)
+
+func newTestStore(t *testing.T) *store.SQLiteStore {
+	t.Helper()
+	st, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "prmoji.db"))
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+	return st
+}
+
+// newClientFor returns clients of a Slack stand-in that only accepts reactions in channel C1.
+// Team T0GONE has no client.
+func newClientFor(t *testing.T) slack.ClientFor {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("channel") == "C1" {
+			_, _ = w.Write([]byte(`{"ok":true}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"ok":false,"error":"channel_not_found"}`))
+	}))
+	t.Cleanup(srv.Close)
+	sc, err := slack.NewClientWithOptions("xoxb-test", slack.Options{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	return func(_ context.Context, teamID string) (*slack.Client, error) {
+		if teamID == "T0GONE" {
+			return nil, errors.New("not installed")
+		}
+		return sc, nil
+	}
+}
+
+func TestRunGivesUpAfterMaxAttempts(t *testing.T) {
+	st := newTestStore(t)
+	clientFor := newClientFor(t)
+	ctx := context.Background()
+
+	for _, channel := range []string{"C1", "C2"} {
+		fr := store.FailedReaction{PRURL: "https://github.com/o/r/pull/1", Channel: channel, TS: "1.0", Emoji: "tada", Error: "timeout"}
+		if err := st.RecordFailedReaction(ctx, fr); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+	}
+	opts := Options{MaxAttempts: 3}
+
+	steps := []struct {
+		name string
+		want Result
+		left int64
+	}{
+		{"first pass", Result{Retried: 2, Succeeded: 1, Failed: 1}, 1},
+		{"last attempt", Result{Retried: 1, GaveUp: 1}, 1},
+		{"after giving up", Result{}, 1},
+	}
+	for _, step := range steps {
+		res, err := Run(ctx, st, clientFor, opts)
+		if err != nil {
+			t.Fatalf("%s: run: %v", step.name, err)
+		}
+		res.DurationMS = 0
+		if res != step.want {
+			t.Fatalf("%s: expected %+v got %+v", step.name, step.want, res)
+		}
+		if n, err := st.CountFailedReactions(ctx); err != nil || n != step.left {
+			t.Fatalf("%s: expected %d failed reactions left got %d (%v)", step.name, step.left, n, err)
+		}
+	}
+
+	left, err := st.ListFailedReactions(ctx, 10, 0)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if left[0].Channel != "C2" || left[0].Attempts != 3 || left[0].Error != "slack api error: channel_not_found" {
+		t.Fatalf("expected the given up reaction to be kept with its last error, got %+v", left[0])
+	}
+}
+
+func TestRunBatchSize(t *testing.T) {
+	st := newTestStore(t)
+	ctx := context.Background()
+	for _, ts := range []string{"1.0", "2.0", "3.0"} {
+		fr := store.FailedReaction{PRURL: "https://github.com/o/r/pull/1", Channel: "C1", TS: ts, Emoji: "tada", Error: "timeout"}
+		if err := st.RecordFailedReaction(ctx, fr); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+	}
+
+	res, err := Run(ctx, st, newClientFor(t), Options{MaxAttempts: 5, BatchSize: 2})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if res.Retried != 2 || res.Succeeded != 2 {
+		t.Fatalf("expected one batch of 2, got %+v", res)
+	}
+}
+
+func TestAttemptWithoutClient(t *testing.T) {
+	st := newTestStore(t)
+	ctx := context.Background()
+	fr := store.FailedReaction{PRURL: "https://github.com/o/r/pull/1", TeamID: "T0GONE", Channel: "C1", TS: "1.0", Emoji: "tada", Error: "timeout"}
+	if err := st.RecordFailedReaction(ctx, fr); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	frs, err := st.ListFailedReactions(ctx, 10, 0)
+	if err != nil || len(frs) != 1 {
+		t.Fatalf("list: %v %+v", err, frs)
+	}
+
+	if err := Attempt(ctx, st, newClientFor(t), frs[0]); err == nil {
+		t.Fatalf("expected an error without a client for the team")
+	}
+	got, err := st.GetFailedReaction(ctx, frs[0].ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got.Attempts != 2 || got.Error != "not installed" {
+		t.Fatalf("expected the attempt to be recorded, got %+v", got)
+	}
+}
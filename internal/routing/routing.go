@@ -0,0 +1,134 @@
+package routing
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// RepoFilter allows and denies repositories by owner/repo globs.
+type RepoFilter struct {
+	// Allow lists owner/repo globs to accept; empty allows every repository not denied.
+	Allow []string
+	// Deny lists owner/repo globs never to accept; it wins over Allow.
+	Deny []string
+}
+
+// Rule limits the repositories tracked in the channels it matches.
+type Rule struct {
+	// Channel is a glob over channel IDs, or over channel names when it starts with '#'.
+	Channel string
+	RepoFilter
+}
+
+// Rules are evaluated in order and the first rule matching a channel decides. Links in
+// channels no rule matches are tracked.
+type Rules []Rule
+
+// Parse reads rules written as `CHANNEL=REPO,REPO,!REPO;CHANNEL=...`, e.g.
+// `C0DEPS=acme/infra-*;#eng-*=acme/*,!acme/secret-*;*=!*/*`.
+func Parse(s string) (Rules, error) {
+	var rules Rules
+	for _, raw := range strings.Split(s, ";") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		channel, repos, ok := strings.Cut(raw, "=")
+		channel = strings.ToLower(strings.TrimSpace(channel))
+		if !ok || strings.TrimPrefix(channel, "#") == "" {
+			return nil, fmt.Errorf("invalid rule %q: expected CHANNEL=REPOS", raw)
+		}
+		if _, err := path.Match(strings.TrimPrefix(channel, "#"), ""); err != nil {
+			return nil, fmt.Errorf("invalid rule %q: bad channel pattern", raw)
+		}
+		filter, err := ParseRepoFilter(repos)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule %q: %w", raw, err)
+		}
+		if filter.Empty() {
+			return nil, fmt.Errorf("invalid rule %q: no repositories", raw)
+		}
+		rules = append(rules, Rule{Channel: channel, RepoFilter: filter})
+	}
+	return rules, nil
+}
+
+// ParseRepoFilter reads comma-separated owner/repo globs, denying those that start with '!',
+// e.g. `acme/*,!acme/archive-*`.
+func ParseRepoFilter(s string) (RepoFilter, error) {
+	var f RepoFilter
+	for _, repo := range strings.Split(s, ",") {
+		repo = strings.ToLower(strings.TrimSpace(repo))
+		deny := strings.HasPrefix(repo, "!")
+		repo = strings.TrimPrefix(repo, "!")
+		if repo == "" {
+			continue
+		}
+		if _, err := path.Match(repo, ""); err != nil || !strings.Contains(repo, "/") {
+			return RepoFilter{}, fmt.Errorf("bad repository pattern %q (want owner/repo)", repo)
+		}
+		if deny {
+			f.Deny = append(f.Deny, repo)
+		} else {
+			f.Allow = append(f.Allow, repo)
+		}
+	}
+	return f, nil
+}
+
+// Empty reports whether the filter has no patterns and so allows everything.
+func (f RepoFilter) Empty() bool {
+	return len(f.Allow) == 0 && len(f.Deny) == 0
+}
+
+// Allows reports whether repo ("owner/repo") passes the filter.
+func (f RepoFilter) Allows(repo string) bool {
+	repo = strings.ToLower(repo)
+	if matchAny(f.Deny, repo) {
+		return false
+	}
+	return len(f.Allow) == 0 || matchAny(f.Allow, repo)
+}
+
+// UsesNames reports whether any rule matches channels by name, so callers only look names up when needed.
+func (rs Rules) UsesNames() bool {
+	for _, r := range rs {
+		if strings.HasPrefix(r.Channel, "#") {
+			return true
+		}
+	}
+	return false
+}
+
+// Allows reports whether a link to repo ("owner/repo") posted in the channel should be tracked.
+// channelName may be empty when it is unknown; name rules then don't match.
+func (rs Rules) Allows(channelID, channelName, repo string) bool {
+	for _, r := range rs {
+		if r.matchesChannel(strings.ToLower(channelID), strings.ToLower(channelName)) {
+			return r.RepoFilter.Allows(repo)
+		}
+	}
+	return true
+}
+
+func (r Rule) matchesChannel(id, name string) bool {
+	if pattern, ok := strings.CutPrefix(r.Channel, "#"); ok {
+		if name == "" {
+			return false
+		}
+		ok, _ := path.Match(pattern, name)
+		return ok
+	}
+	ok, _ := path.Match(r.Channel, id)
+	return ok
+}
+
+func matchAny(patterns []string, repo string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, repo); ok {
+			return true
+		}
+	}
+	return false
+}
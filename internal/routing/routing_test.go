@@ -0,0 +1,71 @@
+package routing
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	rules, err := Parse(" C0DEPS = acme/infra-* ; #Eng-* = acme/*, !acme/secret-* ;; ")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(rules) != 2 || rules[1].Channel != "#eng-*" || len(rules[1].Allow) != 1 || rules[1].Deny[0] != "acme/secret-*" {
+		t.Fatalf("unexpected rules: %+v", rules)
+	}
+	if !rules.UsesNames() {
+		t.Fatalf("expected UsesNames")
+	}
+
+	for _, bad := range []string{"C0DEPS", "=acme/*", "C0DEPS=acme", "C0DEPS=", "C0DEPS=[acme/*", "#=acme/*"} {
+		if _, err := Parse(bad); err == nil {
+			t.Fatalf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestAllows(t *testing.T) {
+	rules, err := Parse("C0DEPS=acme/infra-*;#eng-*=acme/*,!acme/secret-*;C0RANDOM=!*/*")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	tests := []struct {
+		name              string
+		id, channel, repo string
+		want              bool
+	}{
+		{"allowed by id", "C0DEPS", "deps", "acme/infra-dns", true},
+		{"not in id allow list", "C0DEPS", "deps", "acme/web", false},
+		{"allowed by name", "C1", "eng-web", "Acme/Web", true},
+		{"denied by name", "C1", "eng-web", "acme/secret-keys", false},
+		{"other owner by name", "C1", "eng-web", "other/web", false},
+		{"deny everything", "C0RANDOM", "random", "acme/web", false},
+		{"unknown name skips name rules", "C1", "", "other/web", true},
+		{"no matching rule", "C2", "social", "other/web", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rules.Allows(tt.id, tt.channel, tt.repo); got != tt.want {
+				t.Fatalf("expected %v got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRepoFilter(t *testing.T) {
+	f, err := ParseRepoFilter(" acme/* , !Acme/archive-* ,")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	for repo, want := range map[string]bool{"acme/web": true, "ACME/archive-old": false, "other/web": false} {
+		if got := f.Allows(repo); got != want {
+			t.Fatalf("%s: expected %v got %v", repo, want, got)
+		}
+	}
+	if deny, _ := ParseRepoFilter("!acme/archive-*"); !deny.Allows("other/web") {
+		t.Fatalf("a deny-only filter must allow other repositories")
+	}
+	if empty, _ := ParseRepoFilter(""); !empty.Empty() || !empty.Allows("acme/web") {
+		t.Fatalf("an empty filter must allow everything")
+	}
+	if _, err := ParseRepoFilter("acme"); err == nil {
+		t.Fatalf("expected a pattern without owner to be rejected")
+	}
+}
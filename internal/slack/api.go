@@ -0,0 +1,103 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// APIError is an ok=false response from the Slack Web API.
+type APIError struct {
+	Method string
+	Code   string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("slack %s: %s", e.Method, e.Code)
+}
+
+// RateLimitError is an HTTP 429 from the Slack Web API.
+type RateLimitError struct {
+	Method     string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("slack %s: rate limited, retry after %s", e.Method, e.RetryAfter)
+}
+
+// maxRateLimitRetries bounds how often callWithRetry waits out a 429 for one call.
+const maxRateLimitRetries = 5
+
+// call POSTs form to the Web API method and decodes a successful response into out (if non-nil).
+// ok=false responses are returned as *APIError.
+func (c *Client) call(ctx context.Context, method string, form url.Values, out any) (http.Header, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+method, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("new request: %w", err)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("slack %s: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read slack response: %w", err)
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		secs, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		return nil, &RateLimitError{Method: method, RetryAfter: time.Duration(max(secs, 1)) * time.Second}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("slack http %d: %s", resp.StatusCode, string(b))
+	}
+
+	var apiResp slackAPIResponse
+	if err := json.Unmarshal(b, &apiResp); err != nil {
+		return nil, fmt.Errorf("decode slack response: %w", err)
+	}
+	if !apiResp.OK {
+		code := apiResp.Error
+		if code == "" {
+			code = "unknown_error"
+		}
+		return resp.Header, &APIError{Method: method, Code: code}
+	}
+	if out != nil {
+		if err := json.Unmarshal(b, out); err != nil {
+			return nil, fmt.Errorf("decode slack %s response: %w", method, err)
+		}
+	}
+	return resp.Header, nil
+}
+
+// callWithRetry is call for bulk reads: it waits out rate limits instead of failing.
+func (c *Client) callWithRetry(ctx context.Context, method string, form url.Values, out any) (http.Header, error) {
+	for attempt := 0; ; attempt++ {
+		header, err := c.call(ctx, method, form, out)
+		var rl *RateLimitError
+		if !errors.As(err, &rl) || attempt == maxRateLimitRetries {
+			return header, err
+		}
+		c.log.WarnContext(ctx, "slack rate limited", "method", method, "retry_after", rl.RetryAfter)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(rl.RetryAfter):
+		}
+	}
+}
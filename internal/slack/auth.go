@@ -0,0 +1,39 @@
+package slack
+
+import (
+	"context"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// ScopeReactionsWrite is the bot token scope prmoji needs to add reactions.
+const ScopeReactionsWrite = "reactions:write"
+
+type AuthInfo struct {
+	TeamID string `json:"team_id"`
+	Team   string `json:"team"`
+	UserID string `json:"user_id"`
+	BotID  string `json:"bot_id"`
+	// Scopes are the token's OAuth scopes, taken from the X-OAuth-Scopes response header.
+	Scopes []string `json:"-"`
+}
+
+func (a AuthInfo) HasScope(scope string) bool {
+	return slices.Contains(a.Scopes, scope)
+}
+
+// AuthTest calls auth.test to check that the token is valid and to discover its scopes.
+func (c *Client) AuthTest(ctx context.Context) (AuthInfo, error) {
+	var info AuthInfo
+	header, err := c.call(ctx, "auth.test", url.Values{}, &info)
+	if err != nil {
+		return AuthInfo{}, err
+	}
+	for _, s := range strings.Split(header.Get("X-OAuth-Scopes"), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			info.Scopes = append(info.Scopes, s)
+		}
+	}
+	return info, nil
+}
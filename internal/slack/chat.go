@@ -0,0 +1,35 @@
+package slack
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/adamantal/prmoji/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ScopeChatWrite is the bot token scope needed to reply to app mentions.
+const ScopeChatWrite = "chat:write"
+
+// PostMessage posts text to channel, as a reply in the thread of threadTS when it is set.
+func (c *Client) PostMessage(ctx context.Context, channel, threadTS, text string) (err error) {
+	ctx, span := tracing.Start(ctx, "slack.PostMessage",
+		attribute.String("slack.channel", channel),
+		attribute.String("slack.thread_ts", threadTS),
+	)
+	defer func() {
+		if err != nil {
+			tracing.Fail(span, err)
+		}
+		span.End()
+	}()
+
+	form := url.Values{}
+	form.Set("channel", channel)
+	form.Set("text", text)
+	if threadTS != "" {
+		form.Set("thread_ts", threadTS)
+	}
+	_, err = c.call(ctx, "chat.postMessage", form, nil)
+	return err
+}
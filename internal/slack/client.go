@@ -2,6 +2,8 @@ package slack
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,42 +11,135 @@ import (
 	"log/slog"
 	"net/http"
 	"net/url"
+	"os"
 	"strings"
 	"time"
+
+	"github.com/adamantal/prmoji/internal/metrics"
+	"github.com/adamantal/prmoji/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	DefaultBaseURL = "https://slack.com/api/"
+	defaultTimeout = 10 * time.Second
 )
 
 type Client struct {
-	token string
-	hc    *http.Client
-	log   *slog.Logger
+	token   string
+	baseURL string
+	hc      *http.Client
+	log     *slog.Logger
+}
+
+// Options configure how a Client reaches the Web API. The zero value talks to DefaultBaseURL
+// through the proxy named by the environment (HTTPS_PROXY, NO_PROXY).
+type Options struct {
+	// BaseURL overrides DefaultBaseURL, e.g. to point at a local Slack stand-in.
+	BaseURL string
+	// HTTPClient is used as is when set; Proxy, CAFile and Timeout are then ignored.
+	HTTPClient *http.Client
+	// Proxy is an http(s) proxy URL used instead of the environment's.
+	Proxy string
+	// CAFile is a PEM bundle of CAs trusted in addition to the system roots.
+	CAFile string
+	// Timeout bounds each call, including reading the response; 0 keeps the 10s default.
+	Timeout time.Duration
 }
 
 func NewClient(token string) *Client {
 	return &Client{
-		token: token,
+		token:   token,
+		baseURL: DefaultBaseURL,
 		hc: &http.Client{
-			Timeout: 10 * time.Second,
+			Timeout: defaultTimeout,
 		},
 		log: slog.Default(),
 	}
 }
 
+// NewClientWithOptions is NewClient with a custom base URL or transport. It fails when the
+// proxy URL or the CA bundle can't be used.
+func NewClientWithOptions(token string, opts Options) (*Client, error) {
+	c := NewClient(token)
+	if opts.BaseURL != "" {
+		c.baseURL = opts.BaseURL
+		if !strings.HasSuffix(c.baseURL, "/") {
+			c.baseURL += "/"
+		}
+	}
+	if opts.HTTPClient != nil {
+		c.hc = opts.HTTPClient
+		return c, nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if opts.Proxy != "" {
+		proxy, err := url.Parse(opts.Proxy)
+		if err != nil || (proxy.Scheme != "http" && proxy.Scheme != "https") || proxy.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL: %q", opts.Proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	if opts.CAFile != "" {
+		pem, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read CA bundle: %w", err)
+		}
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", opts.CAFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}
+	}
+	if opts.Timeout > 0 {
+		c.hc.Timeout = opts.Timeout
+	}
+	c.hc.Transport = transport
+	return c, nil
+}
+
+// ClientFor returns the client for a Slack workspace (team ID).
+type ClientFor func(ctx context.Context, teamID string) (*Client, error)
+
+// WithToken returns a client for another workspace's bot token that shares c's settings.
+func (c *Client) WithToken(token string) *Client {
+	cp := *c
+	cp.token = token
+	return &cp
+}
+
 type slackAPIResponse struct {
 	OK    bool   `json:"ok"`
 	Error string `json:"error"`
 }
 
-func (c *Client) AddReaction(ctx context.Context, channel, timestamp, emojiName string) error {
-	c.log.Debug("adding reaction", "channel", channel, "timestamp", timestamp, "emoji", emojiName)
+func (c *Client) AddReaction(ctx context.Context, channel, timestamp, emojiName string) (err error) {
+	ctx, span := tracing.Start(ctx, "slack.AddReaction",
+		attribute.String("slack.channel", channel),
+		attribute.String("slack.ts", timestamp),
+		attribute.String("slack.emoji", emojiName),
+	)
+	defer func() {
+		if err != nil {
+			tracing.Fail(span, err)
+		}
+		span.End()
+	}()
+
+	c.log.DebugContext(ctx, "adding reaction", "channel", channel, "timestamp", timestamp, "emoji", emojiName)
 
 	form := url.Values{}
 	form.Set("channel", channel)
 	form.Set("timestamp", timestamp)
 	form.Set("name", emojiName)
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://slack.com/api/reactions.add", strings.NewReader(form.Encode()))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"reactions.add", strings.NewReader(form.Encode()))
 	if err != nil {
-		c.log.Error("failed to build slack request", "err", err, "channel", channel, "timestamp", timestamp, "emoji", emojiName)
+		c.log.ErrorContext(ctx, "failed to build slack request", "err", err, "channel", channel, "timestamp", timestamp, "emoji", emojiName)
 		return fmt.Errorf("new request: %w", err)
 	}
 	req.Header.Set("Authorization", "Bearer "+c.token)
@@ -52,43 +147,56 @@ func (c *Client) AddReaction(ctx context.Context, channel, timestamp, emojiName
 
 	resp, err := c.hc.Do(req)
 	if err != nil {
-		c.log.Error("slack request failed", "err", err, "channel", channel, "timestamp", timestamp, "emoji", emojiName)
+		c.log.ErrorContext(ctx, "slack request failed", "err", err, "channel", channel, "timestamp", timestamp, "emoji", emojiName)
+		metrics.Reactions.WithLabelValues(emojiName, "request_error").Inc()
 		return fmt.Errorf("slack reactions.add: %w", err)
 	}
 	defer resp.Body.Close()
 
 	b, err := io.ReadAll(resp.Body)
 	if err != nil {
-		c.log.Error("failed reading slack response", "err", err, "channel", channel, "timestamp", timestamp, "emoji", emojiName)
+		c.log.ErrorContext(ctx, "failed reading slack response", "err", err, "channel", channel, "timestamp", timestamp, "emoji", emojiName)
+		metrics.Reactions.WithLabelValues(emojiName, "read_error").Inc()
 		return fmt.Errorf("read slack response: %w", err)
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		c.log.Error("slack http error", "status", resp.StatusCode, "body", string(b), "channel", channel, "timestamp", timestamp, "emoji", emojiName)
+		c.log.ErrorContext(ctx, "slack http error", "status", resp.StatusCode, "body", string(b), "channel", channel, "timestamp", timestamp, "emoji", emojiName)
+		metrics.Reactions.WithLabelValues(emojiName, "http_error").Inc()
 		return fmt.Errorf("slack http %d: %s", resp.StatusCode, string(b))
 	}
 
 	var apiResp slackAPIResponse
 	if err := json.Unmarshal(b, &apiResp); err != nil {
-		c.log.Error("failed decoding slack response", "err", err, "body", string(b), "channel", channel, "timestamp", timestamp, "emoji", emojiName)
+		c.log.ErrorContext(ctx, "failed decoding slack response", "err", err, "body", string(b), "channel", channel, "timestamp", timestamp, "emoji", emojiName)
+		metrics.Reactions.WithLabelValues(emojiName, "decode_error").Inc()
 		return fmt.Errorf("decode slack response: %w", err)
 	}
+	result := apiResp.Error
+	if apiResp.OK {
+		result = "ok"
+	} else if result == "" {
+		result = "unknown_error"
+	}
+	metrics.Reactions.WithLabelValues(emojiName, result).Inc()
+	span.SetAttributes(attribute.String("slack.result", result))
+
 	if apiResp.OK {
-		c.log.Debug("reaction added", "channel", channel, "timestamp", timestamp, "emoji", emojiName)
+		c.log.DebugContext(ctx, "reaction added", "channel", channel, "timestamp", timestamp, "emoji", emojiName)
 		return nil
 	}
 	if apiResp.Error == "already_reacted" {
-		c.log.Debug("reaction already present", "channel", channel, "timestamp", timestamp, "emoji", emojiName)
+		c.log.DebugContext(ctx, "reaction already present", "channel", channel, "timestamp", timestamp, "emoji", emojiName)
 		return nil
 	}
 	if apiResp.Error == "message_not_found" {
-		c.log.Warn("message not found", "channel", channel, "timestamp", timestamp, "emoji", emojiName)
+		c.log.WarnContext(ctx, "message not found", "channel", channel, "timestamp", timestamp, "emoji", emojiName)
 		return nil
 	}
 	if apiResp.Error == "" {
-		c.log.Error("slack api error", "channel", channel, "timestamp", timestamp, "emoji", emojiName)
+		c.log.ErrorContext(ctx, "slack api error", "channel", channel, "timestamp", timestamp, "emoji", emojiName)
 		return errors.New("slack api error")
 	}
-	c.log.Error("slack api error", "error", apiResp.Error, "channel", channel, "timestamp", timestamp, "emoji", emojiName)
+	c.log.ErrorContext(ctx, "slack api error", "error", apiResp.Error, "channel", channel, "timestamp", timestamp, "emoji", emojiName)
 	return fmt.Errorf("slack api error: %s", apiResp.Error)
 }
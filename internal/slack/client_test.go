@@ -0,0 +1,185 @@
+package slack
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestClient(t *testing.T, h http.HandlerFunc) *Client {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	c := NewClient("xoxb-test")
+	c.baseURL = srv.URL + "/"
+	return c
+}
+
+func TestAuthTest(t *testing.T) {
+	t.Run("returns identity and scopes", func(t *testing.T) {
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/auth.test" {
+				t.Errorf("unexpected path: %s", r.URL.Path)
+			}
+			if r.Header.Get("Authorization") != "Bearer xoxb-test" {
+				t.Errorf("unexpected auth header: %q", r.Header.Get("Authorization"))
+			}
+			w.Header().Set("X-OAuth-Scopes", "channels:history, reactions:write")
+			_, _ = w.Write([]byte(`{"ok":true,"team":"Acme","team_id":"T1","user_id":"U1"}`))
+		})
+		info, err := c.AuthTest(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if info.TeamID != "T1" || !info.HasScope(ScopeReactionsWrite) {
+			t.Fatalf("unexpected info: %#v", info)
+		}
+	})
+
+	t.Run("surfaces api errors", func(t *testing.T) {
+		c := newTestClient(t, func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(`{"ok":false,"error":"invalid_auth"}`))
+		})
+		_, err := c.AuthTest(context.Background())
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.Code != "invalid_auth" {
+			t.Fatalf("expected invalid_auth APIError got %v", err)
+		}
+	})
+}
+
+func TestPostMessage(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat.postMessage" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("parse form: %v", err)
+		}
+		if r.PostForm.Get("channel") != "C1" || r.PostForm.Get("thread_ts") != "1.0" || r.PostForm.Get("text") != "hi" {
+			t.Errorf("unexpected form: %v", r.PostForm)
+		}
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	})
+	if err := c.PostMessage(context.Background(), "C1", "1.0", "hi"); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+}
+
+func TestHistoryWaitsOutRateLimit(t *testing.T) {
+	calls := 0
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("parse form: %v", err)
+		}
+		if r.PostForm.Get("oldest") != "1700000000.000000" || r.PostForm.Get("cursor") != "c1" {
+			t.Errorf("unexpected form: %v", r.PostForm)
+		}
+		_, _ = w.Write([]byte(`{"ok":true,"messages":[{"ts":"1700000001.000100","text":"hi","reply_count":2}],"response_metadata":{"next_cursor":"c2"}}`))
+	})
+	msgs, next, err := c.History(context.Background(), "C1", TS(time.Unix(1_700_000_000, 0)), "c1")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if calls != 2 || len(msgs) != 1 || msgs[0].ReplyCount != 2 || next != "c2" {
+		t.Fatalf("unexpected result after %d calls: %+v next=%q", calls, msgs, next)
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestNewClientWithOptions(t *testing.T) {
+	ok := func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"ok":true,"team_id":"T1"}`))
+	}
+
+	t.Run("uses the injected http client and base URL", func(t *testing.T) {
+		var got string
+		hc := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			got = r.URL.String()
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"ok":true}`)), Request: r}, nil
+		})}
+		c, err := NewClientWithOptions("xoxb-test", Options{BaseURL: "http://slack.local/api", HTTPClient: hc})
+		if err != nil {
+			t.Fatalf("new client: %v", err)
+		}
+		if _, err := c.AuthTest(context.Background()); err != nil {
+			t.Fatalf("auth test: %v", err)
+		}
+		if got != "http://slack.local/api/auth.test" {
+			t.Fatalf("unexpected url: %s", got)
+		}
+	})
+
+	t.Run("trusts the CA bundle", func(t *testing.T) {
+		srv := httptest.NewTLSServer(http.HandlerFunc(ok))
+		t.Cleanup(srv.Close)
+		untrusting, err := NewClientWithOptions("xoxb-test", Options{BaseURL: srv.URL})
+		if err != nil {
+			t.Fatalf("new client: %v", err)
+		}
+		if _, err := untrusting.AuthTest(context.Background()); err == nil {
+			t.Fatalf("expected the test server's certificate to be untrusted")
+		}
+
+		caFile := filepath.Join(t.TempDir(), "ca.pem")
+		block := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+		if err := os.WriteFile(caFile, block, 0o600); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		c, err := NewClientWithOptions("xoxb-test", Options{BaseURL: srv.URL, CAFile: caFile})
+		if err != nil {
+			t.Fatalf("new client: %v", err)
+		}
+		if _, err := c.AuthTest(context.Background()); err != nil {
+			t.Fatalf("auth test: %v", err)
+		}
+	})
+
+	t.Run("goes through the proxy", func(t *testing.T) {
+		var host string
+		proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host = r.URL.Host
+			ok(w, r)
+		}))
+		t.Cleanup(proxy.Close)
+		c, err := NewClientWithOptions("xoxb-test", Options{BaseURL: "http://slack.invalid/api/", Proxy: proxy.URL})
+		if err != nil {
+			t.Fatalf("new client: %v", err)
+		}
+		if _, err := c.AuthTest(context.Background()); err != nil {
+			t.Fatalf("auth test: %v", err)
+		}
+		if host != "slack.invalid" {
+			t.Fatalf("expected the proxy to get the request for slack.invalid, got %q", host)
+		}
+	})
+
+	t.Run("rejects unusable settings", func(t *testing.T) {
+		notPEM := filepath.Join(t.TempDir(), "ca.pem")
+		if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o600); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		for _, opts := range []Options{{Proxy: "proxy:3128"}, {CAFile: notPEM}, {CAFile: notPEM + ".missing"}} {
+			if _, err := NewClientWithOptions("xoxb-test", opts); err == nil {
+				t.Fatalf("expected %+v to be rejected", opts)
+			}
+		}
+	})
+}
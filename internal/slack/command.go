@@ -0,0 +1,79 @@
+package slack
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRequestAge is how far X-Slack-Request-Timestamp may be from now before a request is
+// treated as a replay.
+const maxRequestAge = 5 * time.Minute
+
+var ErrInvalidSignature = errors.New("invalid slack signature")
+
+// VerifySignature checks the X-Slack-Signature header of a request against the app's signing secret.
+// See https://api.slack.com/authentication/verifying-requests-from-slack.
+func VerifySignature(secret string, header http.Header, body []byte, now time.Time) error {
+	rawTS := header.Get("X-Slack-Request-Timestamp")
+	ts, err := strconv.ParseInt(rawTS, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: bad timestamp %q", ErrInvalidSignature, rawTS)
+	}
+	if age := now.Sub(time.Unix(ts, 0)); age > maxRequestAge || age < -maxRequestAge {
+		return fmt.Errorf("%w: timestamp too old", ErrInvalidSignature)
+	}
+	want := Signature(secret, rawTS, body)
+	if !hmac.Equal([]byte(header.Get("X-Slack-Signature")), []byte(want)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Signature computes the v0 request signature Slack sends for body at timestamp ts.
+func Signature(secret, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + ts + ":"))
+	mac.Write(body)
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// SlashCommand is the form payload Slack POSTs for a slash command invocation.
+type SlashCommand struct {
+	Command     string
+	Text        string
+	TeamID      string
+	ChannelID   string
+	UserID      string
+	ResponseURL string
+}
+
+func ParseSlashCommand(body []byte) (SlashCommand, error) {
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return SlashCommand{}, err
+	}
+	return SlashCommand{
+		Command:     form.Get("command"),
+		Text:        strings.TrimSpace(form.Get("text")),
+		TeamID:      form.Get("team_id"),
+		ChannelID:   form.Get("channel_id"),
+		UserID:      form.Get("user_id"),
+		ResponseURL: form.Get("response_url"),
+	}, nil
+}
+
+const ResponseEphemeral = "ephemeral"
+
+// CommandResponse is the immediate reply to a slash command.
+type CommandResponse struct {
+	ResponseType string `json:"response_type"`
+	Text         string `json:"text"`
+}
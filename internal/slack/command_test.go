@@ -0,0 +1,48 @@
+package slack
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerifySignature(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	body := []byte("command=%2Fprmoji&text=help")
+	signed := func(ts time.Time, secret string) http.Header {
+		raw := strconv.FormatInt(ts.Unix(), 10)
+		h := http.Header{}
+		h.Set("X-Slack-Request-Timestamp", raw)
+		h.Set("X-Slack-Signature", Signature(secret, raw, body))
+		return h
+	}
+
+	t.Run("accepts a valid signature", func(t *testing.T) {
+		if err := VerifySignature("secret", signed(now, "secret"), body, now); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("rejects a wrong secret", func(t *testing.T) {
+		err := VerifySignature("secret", signed(now, "other"), body, now)
+		if !errors.Is(err, ErrInvalidSignature) {
+			t.Fatalf("expected ErrInvalidSignature got %v", err)
+		}
+	})
+
+	t.Run("rejects stale requests", func(t *testing.T) {
+		err := VerifySignature("secret", signed(now.Add(-10*time.Minute), "secret"), body, now)
+		if !errors.Is(err, ErrInvalidSignature) {
+			t.Fatalf("expected ErrInvalidSignature got %v", err)
+		}
+	})
+
+	t.Run("rejects a tampered body", func(t *testing.T) {
+		err := VerifySignature("secret", signed(now, "secret"), []byte("command=%2Fprmoji&text=list"), now)
+		if !errors.Is(err, ErrInvalidSignature) {
+			t.Fatalf("expected ErrInvalidSignature got %v", err)
+		}
+	})
+}
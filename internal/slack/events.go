@@ -3,20 +3,73 @@ package slack
 import (
 	"encoding/json"
 	"regexp"
+	"strings"
 )
 
 type EventEnvelope struct {
-	Challenge string     `json:"challenge"`
-	Event     SlackEvent `json:"event"`
+	Type           string          `json:"type"`
+	EventID        string          `json:"event_id"`
+	TeamID         string          `json:"team_id"`
+	Challenge      string          `json:"challenge"`
+	Event          SlackEvent      `json:"event"`
+	Authorizations []Authorization `json:"authorizations"`
+}
+
+// EventLabel normalizes a Slack event type for use as a metric label, folding event types
+// prmoji doesn't handle into "other" to keep label cardinality bounded.
+func EventLabel(eventType string) string {
+	switch e := strings.ToLower(strings.TrimSpace(eventType)); e {
+	case "message", "app_mention", "app_uninstalled", "url_verification":
+		return e
+	default:
+		return "other"
+	}
+}
+
+// Authorization is an installation the event is delivered for.
+type Authorization struct {
+	UserID string `json:"user_id"`
+	IsBot  bool   `json:"is_bot"`
+}
+
+// BotUserID returns the user ID of the bot the event was delivered to, if Slack included it.
+func (e EventEnvelope) BotUserID() string {
+	for _, a := range e.Authorizations {
+		if a.IsBot {
+			return a.UserID
+		}
+	}
+	return ""
 }
 
 type SlackEvent struct {
+	Type    string `json:"type"`
+	User    string `json:"user"`
 	Text    string `json:"text"`
 	Channel string `json:"channel"`
-	EventTS string `json:"event_ts"`
+	TS      string `json:"ts"`
+	// ThreadTS is the parent message's ts for thread replies (and equals TS on the parent itself).
+	ThreadTS string `json:"thread_ts"`
+	EventTS  string `json:"event_ts"`
+}
+
+// InThread reports whether the event is a reply in a thread rather than a top-level message.
+func (e SlackEvent) InThread() bool {
+	return e.ThreadTS != "" && e.ThreadTS != e.TS
 }
 
-var prURLRe = regexp.MustCompile(`https://github\.com/[^/\s]+/[^/\s]+/pull/\d+`)
+// ReplyTo returns the ts of the thread parent for a thread reply, and "" otherwise.
+func (e SlackEvent) ReplyTo() string {
+	if !e.InThread() {
+		return ""
+	}
+	return e.ThreadTS
+}
+
+var (
+	prURLRe   = regexp.MustCompile(`https://github\.com/[^/\s]+/[^/\s]+/pull/\d+`)
+	mentionRe = regexp.MustCompile(`^\s*<@([A-Z0-9]+)(?:\|[^>]*)?>`)
+)
 
 func ParseEnvelope(body []byte) (EventEnvelope, error) {
 	var env EventEnvelope
@@ -26,6 +79,16 @@ func ParseEnvelope(body []byte) (EventEnvelope, error) {
 	return env, nil
 }
 
+// ParseMention splits text that starts with a user mention into the mentioned user ID and the
+// remaining words, e.g. "<@U1> track https://…" yields "U1" and ["track", "https://…"].
+func ParseMention(text string) (userID string, words []string, ok bool) {
+	m := mentionRe.FindStringSubmatchIndex(text)
+	if m == nil {
+		return "", nil, false
+	}
+	return text[m[2]:m[3]], strings.Fields(text[m[1]:]), true
+}
+
 func ExtractPRURLs(text string) []string {
 	if text == "" {
 		return nil
@@ -46,3 +109,9 @@ func ExtractPRURLs(text string) []string {
 	}
 	return out
 }
+
+// IsPRURL reports whether s is exactly one PR URL as recognized in Slack messages.
+func IsPRURL(s string) bool {
+	urls := ExtractPRURLs(s)
+	return len(urls) == 1 && urls[0] == s
+}
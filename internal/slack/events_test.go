@@ -38,3 +38,50 @@ func TestExtractPRURLs(t *testing.T) {
 		}
 	})
 }
+
+func TestIsPRURL(t *testing.T) {
+	for s, want := range map[string]bool{
+		"https://github.com/a/b/pull/1":       true,
+		"https://github.com/a/b/pull/1/files": false,
+		"see https://github.com/a/b/pull/1":   false,
+		"https://github.com/a/b/issues/1":     false,
+		"":                                    false,
+	} {
+		if got := IsPRURL(s); got != want {
+			t.Fatalf("IsPRURL(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
+
+func TestEventLabel(t *testing.T) {
+	for eventType, want := range map[string]string{
+		"message":          "message",
+		"App_Mention":      "app_mention",
+		"app_uninstalled":  "app_uninstalled",
+		"url_verification": "url_verification",
+		"reaction_added":   "other",
+		"":                 "other",
+	} {
+		if got := EventLabel(eventType); got != want {
+			t.Fatalf("EventLabel(%q) = %q, want %q", eventType, got, want)
+		}
+	}
+}
+
+func TestParseMention(t *testing.T) {
+	t.Run("splits the mention from the command", func(t *testing.T) {
+		user, words, ok := ParseMention("<@U0BOT> track  <https://github.com/a/b/pull/1>")
+		if !ok || user != "U0BOT" {
+			t.Fatalf("expected mention of U0BOT got %q ok=%v", user, ok)
+		}
+		if len(words) != 2 || words[0] != "track" || words[1] != "<https://github.com/a/b/pull/1>" {
+			t.Fatalf("unexpected words: %q", words)
+		}
+	})
+
+	t.Run("requires a leading mention", func(t *testing.T) {
+		if _, _, ok := ParseMention("please <@U0BOT> track"); ok {
+			t.Fatalf("expected no mention")
+		}
+	})
+}
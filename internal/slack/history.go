@@ -0,0 +1,117 @@
+package slack
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// historyPageSize is the page size requested from conversations.* methods (Slack's recommended maximum).
+const historyPageSize = 200
+
+// HistoryMessage is a message as returned by conversations.history and conversations.replies.
+type HistoryMessage struct {
+	Type       string `json:"type"`
+	Subtype    string `json:"subtype"`
+	TS         string `json:"ts"`
+	ThreadTS   string `json:"thread_ts"`
+	Text       string `json:"text"`
+	ReplyCount int    `json:"reply_count"`
+}
+
+type responseMetadata struct {
+	NextCursor string `json:"next_cursor"`
+}
+
+type historyPage struct {
+	Messages []HistoryMessage `json:"messages"`
+	Meta     responseMetadata `json:"response_metadata"`
+}
+
+// TS formats t as a Slack message timestamp, e.g. for the oldest parameter.
+func TS(t time.Time) string {
+	return fmt.Sprintf("%d.%06d", t.Unix(), t.Nanosecond()/1000)
+}
+
+// History returns one page of channel messages posted after oldest, and the cursor of the next
+// page ("" on the last page). Rate limits are waited out.
+func (c *Client) History(ctx context.Context, channel, oldest, cursor string) ([]HistoryMessage, string, error) {
+	form := url.Values{}
+	form.Set("channel", channel)
+	form.Set("oldest", oldest)
+	form.Set("limit", strconv.Itoa(historyPageSize))
+	if cursor != "" {
+		form.Set("cursor", cursor)
+	}
+	var page historyPage
+	if _, err := c.callWithRetry(ctx, "conversations.history", form, &page); err != nil {
+		return nil, "", err
+	}
+	return page.Messages, page.Meta.NextCursor, nil
+}
+
+// Replies returns one page of a thread (parent first) posted after oldest, like History.
+func (c *Client) Replies(ctx context.Context, channel, threadTS, oldest, cursor string) ([]HistoryMessage, string, error) {
+	form := url.Values{}
+	form.Set("channel", channel)
+	form.Set("ts", threadTS)
+	form.Set("oldest", oldest)
+	form.Set("limit", strconv.Itoa(historyPageSize))
+	if cursor != "" {
+		form.Set("cursor", cursor)
+	}
+	var page historyPage
+	if _, err := c.callWithRetry(ctx, "conversations.replies", form, &page); err != nil {
+		return nil, "", err
+	}
+	return page.Messages, page.Meta.NextCursor, nil
+}
+
+// BotChannels returns the IDs of all public and private channels the bot is a member of.
+func (c *Client) BotChannels(ctx context.Context) ([]string, error) {
+	var (
+		ids    []string
+		cursor string
+	)
+	for {
+		form := url.Values{}
+		form.Set("types", "public_channel,private_channel")
+		form.Set("exclude_archived", "true")
+		form.Set("limit", strconv.Itoa(historyPageSize))
+		if cursor != "" {
+			form.Set("cursor", cursor)
+		}
+		var page struct {
+			Channels []struct {
+				ID string `json:"id"`
+			} `json:"channels"`
+			Meta responseMetadata `json:"response_metadata"`
+		}
+		if _, err := c.callWithRetry(ctx, "users.conversations", form, &page); err != nil {
+			return nil, err
+		}
+		for _, ch := range page.Channels {
+			ids = append(ids, ch.ID)
+		}
+		if cursor = page.Meta.NextCursor; cursor == "" {
+			return ids, nil
+		}
+	}
+}
+
+// ChannelName returns a channel's name (without '#') via conversations.info.
+func (c *Client) ChannelName(ctx context.Context, channel string) (string, error) {
+	form := url.Values{}
+	form.Set("channel", channel)
+	var out struct {
+		Channel struct {
+			Name string `json:"name"`
+		} `json:"channel"`
+	}
+	if _, err := c.callWithRetry(ctx, "conversations.info", form, &out); err != nil {
+		return "", err
+	}
+	return out.Channel.Name, nil
+}
@@ -0,0 +1,114 @@
+package slack
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const authorizeURL = "https://slack.com/oauth/v2/authorize"
+
+// OAuthStateMaxAge is how long a user has to approve an install before its state expires.
+const OAuthStateMaxAge = 10 * time.Minute
+
+// BotScopes are the bot token scopes requested when prmoji is installed through OAuth.
+var BotScopes = []string{
+	ScopeReactionsWrite,
+	ScopeChatWrite,
+	"app_mentions:read",
+	"channels:history",
+	"groups:history",
+	"channels:read",
+	"groups:read",
+	"commands",
+}
+
+var ErrInvalidOAuthState = errors.New("invalid oauth state")
+
+// AuthorizeURL returns the Slack page that asks a user to install the app with BotScopes.
+// redirectURI may be empty to use the one configured for the app.
+func AuthorizeURL(clientID, redirectURI, state string) string {
+	q := url.Values{}
+	q.Set("client_id", clientID)
+	q.Set("scope", strings.Join(BotScopes, ","))
+	q.Set("state", state)
+	if redirectURI != "" {
+		q.Set("redirect_uri", redirectURI)
+	}
+	return authorizeURL + "?" + q.Encode()
+}
+
+// NewOAuthNonce returns a random value that ties an install's state to the browser that started
+// it; the install handler keeps it in a cookie.
+func NewOAuthNonce() string {
+	return rand.Text()
+}
+
+// OAuthState returns a state parameter for an install started at now by the browser holding
+// nonce, signed with secret so the callback can tell it was issued by us without storing it.
+func OAuthState(secret, nonce string, now time.Time) string {
+	ts := strconv.FormatInt(now.Unix(), 10)
+	return ts + "." + oauthStateMAC(secret, nonce, ts)
+}
+
+// VerifyOAuthState checks a state returned to the OAuth callback against the nonce of the
+// browser it was returned to.
+func VerifyOAuthState(secret, nonce, state string, now time.Time) error {
+	ts, mac, ok := strings.Cut(state, ".")
+	if !ok || nonce == "" || !hmac.Equal([]byte(mac), []byte(oauthStateMAC(secret, nonce, ts))) {
+		return ErrInvalidOAuthState
+	}
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrInvalidOAuthState
+	}
+	if age := now.Sub(time.Unix(sec, 0)); age > OAuthStateMaxAge || age < -time.Minute {
+		return ErrInvalidOAuthState
+	}
+	return nil
+}
+
+func oauthStateMAC(secret, nonce, ts string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("prmoji-oauth:" + nonce + ":" + ts))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// OAuthAccess is the result of exchanging an OAuth code for a bot token.
+type OAuthAccess struct {
+	AccessToken string `json:"access_token"`
+	Scope       string `json:"scope"`
+	BotUserID   string `json:"bot_user_id"`
+	AppID       string `json:"app_id"`
+	Team        struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"team"`
+}
+
+// OAuthV2Access exchanges the code Slack passed to the redirect URL for the workspace's bot token.
+// It authenticates with the client credentials, so c needs no token.
+func (c *Client) OAuthV2Access(ctx context.Context, clientID, clientSecret, code, redirectURI string) (OAuthAccess, error) {
+	form := url.Values{}
+	form.Set("client_id", clientID)
+	form.Set("client_secret", clientSecret)
+	form.Set("code", code)
+	if redirectURI != "" {
+		form.Set("redirect_uri", redirectURI)
+	}
+	var out OAuthAccess
+	if _, err := c.call(ctx, "oauth.v2.access", form, &out); err != nil {
+		return OAuthAccess{}, err
+	}
+	if out.AccessToken == "" || out.Team.ID == "" {
+		return OAuthAccess{}, errors.New("oauth.v2.access returned no bot token")
+	}
+	return out, nil
+}
@@ -0,0 +1,62 @@
+package slack
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestVerifyOAuthState(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	nonce := NewOAuthNonce()
+	state := OAuthState("secret", nonce, now)
+
+	if err := VerifyOAuthState("secret", nonce, state, now.Add(time.Minute)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for name, tc := range map[string]struct {
+		secret, nonce, state string
+		at                   time.Time
+	}{
+		"wrong secret":  {"other", nonce, state, now},
+		"other browser": {"secret", NewOAuthNonce(), state, now},
+		"no cookie":     {"secret", "", state, now},
+		"expired":       {"secret", nonce, state, now.Add(11 * time.Minute)},
+		"tampered":      {"secret", nonce, "1700000001" + state[10:], now},
+		"malformed":     {"secret", nonce, "nonsense", now},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if err := VerifyOAuthState(tc.secret, tc.nonce, tc.state, tc.at); !errors.Is(err, ErrInvalidOAuthState) {
+				t.Fatalf("expected ErrInvalidOAuthState got %v", err)
+			}
+		})
+	}
+}
+
+func TestOAuthV2Access(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/oauth.v2.access" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "" {
+			t.Errorf("unexpected auth header: %q", r.Header.Get("Authorization"))
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("parse form: %v", err)
+		}
+		if r.PostForm.Get("code") != "abc" || r.PostForm.Get("client_secret") != "shh" {
+			t.Errorf("unexpected form: %v", r.PostForm)
+		}
+		_, _ = w.Write([]byte(`{"ok":true,"access_token":"xoxb-t2","scope":"reactions:write","bot_user_id":"U2","team":{"id":"T2","name":"Beta"}}`))
+	}).WithToken("")
+
+	access, err := c.OAuthV2Access(context.Background(), "cid", "shh", "abc", "")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if access.AccessToken != "xoxb-t2" || access.Team.ID != "T2" || access.BotUserID != "U2" {
+		t.Fatalf("unexpected access: %+v", access)
+	}
+}
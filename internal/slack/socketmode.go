@@ -0,0 +1,134 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/adamantal/prmoji/internal/metrics"
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+)
+
+// errDisconnectRequested ends a connection Slack asked us to replace; we reconnect without backoff.
+var errDisconnectRequested = errors.New("disconnect requested by slack")
+
+// OpenConnection calls apps.connections.open and returns a Socket Mode websocket URL.
+// The client must use an app-level token (xapp-...) with the connections:write scope.
+func (c *Client) OpenConnection(ctx context.Context) (string, error) {
+	var out struct {
+		URL string `json:"url"`
+	}
+	if _, err := c.call(ctx, "apps.connections.open", url.Values{}, &out); err != nil {
+		return "", err
+	}
+	if out.URL == "" {
+		return "", errors.New("apps.connections.open returned no url")
+	}
+	return out.URL, nil
+}
+
+// SocketMode receives Events API payloads over a Socket Mode websocket instead of a public Request URL.
+// See https://api.slack.com/apis/socket-mode.
+type SocketMode struct {
+	api        *Client
+	log        *slog.Logger
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+// NewSocketMode returns a Socket Mode receiver; api must be built with the app-level token.
+func NewSocketMode(api *Client) *SocketMode {
+	return &SocketMode{
+		api:        api,
+		log:        slog.Default(),
+		minBackoff: time.Second,
+		maxBackoff: time.Minute,
+	}
+}
+
+type socketMessage struct {
+	Type       string          `json:"type"`
+	EnvelopeID string          `json:"envelope_id"`
+	Payload    json.RawMessage `json:"payload"`
+	Reason     string          `json:"reason"`
+}
+
+type socketAck struct {
+	EnvelopeID string `json:"envelope_id"`
+}
+
+// Run connects and passes the payload of every events_api envelope to handle until ctx is
+// canceled, reconnecting with exponential backoff when the connection drops. Envelopes are
+// acknowledged before handle is called, so handle should not block for long.
+func (s *SocketMode) Run(ctx context.Context, handle func(context.Context, []byte)) error {
+	backoff := s.minBackoff
+	for {
+		start := time.Now()
+		err := s.connect(ctx, handle)
+		metrics.SocketModeConnected.Set(0)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if errors.Is(err, errDisconnectRequested) {
+			s.log.InfoContext(ctx, "socket mode reconnecting", "reason", err)
+			backoff = s.minBackoff
+			continue
+		}
+		// A connection that lasted a while was healthy; don't keep growing the delay.
+		if time.Since(start) > s.maxBackoff {
+			backoff = s.minBackoff
+		}
+		s.log.WarnContext(ctx, "socket mode connection lost", "err", err, "retry_in", backoff)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, s.maxBackoff)
+	}
+}
+
+func (s *SocketMode) connect(ctx context.Context, handle func(context.Context, []byte)) error {
+	wsURL, err := s.api.OpenConnection(ctx)
+	if err != nil {
+		return fmt.Errorf("open connection: %w", err)
+	}
+	// The API client's transport carries the proxy and CA settings; its timeout only bounds the handshake.
+	conn, _, err := websocket.Dial(ctx, wsURL, &websocket.DialOptions{HTTPClient: s.api.hc})
+	if err != nil {
+		return fmt.Errorf("dial: %w", err)
+	}
+	defer conn.CloseNow()
+	conn.SetReadLimit(1 << 20)
+
+	for {
+		var msg socketMessage
+		if err := wsjson.Read(ctx, conn, &msg); err != nil {
+			return fmt.Errorf("read: %w", err)
+		}
+		switch msg.Type {
+		case "hello":
+			metrics.SocketModeConnected.Set(1)
+			s.log.InfoContext(ctx, "socket mode connected")
+		case "disconnect":
+			_ = conn.Close(websocket.StatusNormalClosure, "")
+			return fmt.Errorf("%w (%s)", errDisconnectRequested, msg.Reason)
+		default:
+			if msg.EnvelopeID != "" {
+				if err := wsjson.Write(ctx, conn, socketAck{EnvelopeID: msg.EnvelopeID}); err != nil {
+					return fmt.Errorf("ack: %w", err)
+				}
+			}
+			if msg.Type != "events_api" {
+				s.log.DebugContext(ctx, "ignoring socket mode envelope", "type", msg.Type)
+				continue
+			}
+			handle(ctx, msg.Payload)
+		}
+	}
+}
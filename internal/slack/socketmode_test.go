@@ -0,0 +1,103 @@
+package slack
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+)
+
+// socketStandIn serves apps.connections.open and a websocket that sends one event per
+// connection, then asks the client to reconnect.
+func socketStandIn(t *testing.T, acks chan<- string) *httptest.Server {
+	t.Helper()
+	var conns atomic.Int32
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/apps.connections.open":
+			if r.Header.Get("Authorization") != "Bearer xapp-test" {
+				t.Errorf("unexpected auth header: %q", r.Header.Get("Authorization"))
+			}
+			_, _ = w.Write([]byte(`{"ok":true,"url":"ws` + strings.TrimPrefix(srv.URL, "http") + `/ws"}`))
+		case "/ws":
+			conn, err := websocket.Accept(w, r, nil)
+			if err != nil {
+				t.Errorf("accept: %v", err)
+				return
+			}
+			defer conn.CloseNow()
+			ctx := r.Context()
+			n := conns.Add(1)
+
+			_ = wsjson.Write(ctx, conn, map[string]any{"type": "hello"})
+			_ = wsjson.Write(ctx, conn, map[string]any{
+				"type":        "events_api",
+				"envelope_id": fmt.Sprintf("env-%d", n),
+				"payload":     map[string]any{"type": "event_callback", "event_id": fmt.Sprintf("Ev%d", n)},
+			})
+			var ack socketAck
+			if err := wsjson.Read(ctx, conn, &ack); err != nil {
+				return
+			}
+			acks <- ack.EnvelopeID
+			_ = wsjson.Write(ctx, conn, map[string]any{"type": "disconnect", "reason": "refresh_requested"})
+			_, _, _ = conn.Read(ctx)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestSocketModeRun(t *testing.T) {
+	acks := make(chan string, 4)
+	srv := socketStandIn(t, acks)
+
+	api := NewClient("xapp-test")
+	api.baseURL = srv.URL + "/"
+	sm := NewSocketMode(api)
+	sm.minBackoff = 10 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	payloads := make(chan string, 4)
+	done := make(chan error, 1)
+	go func() {
+		done <- sm.Run(ctx, func(_ context.Context, payload []byte) { payloads <- string(payload) })
+	}()
+
+	// Two connections prove that a disconnect request leads to a reconnect.
+	for i, want := range []string{"env-1", "env-2"} {
+		select {
+		case got := <-acks:
+			if got != want {
+				t.Fatalf("ack %d: expected %s got %s", i, want, got)
+			}
+		case <-ctx.Done():
+			t.Fatalf("timed out waiting for ack %d", i)
+		}
+		select {
+		case p := <-payloads:
+			if !strings.Contains(p, `"event_id":"Ev`) {
+				t.Fatalf("unexpected payload: %s", p)
+			}
+		case <-ctx.Done():
+			t.Fatalf("timed out waiting for payload %d", i)
+		}
+	}
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("expected context.Canceled got %v", err)
+	}
+}
@@ -3,11 +3,18 @@ package store
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"strings"
 	"time"
 
+	"github.com/adamantal/prmoji/internal/metrics"
+	"github.com/adamantal/prmoji/internal/tracing"
 	_ "github.com/mattn/go-sqlite3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -16,28 +23,334 @@ const (
 		inserted_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
 		pr_url TEXT NOT NULL,
 		message_channel TEXT,
-		message_timestamp TEXT
+		message_timestamp TEXT,
+		team_id TEXT NOT NULL DEFAULT '',
+		thread_ts TEXT NOT NULL DEFAULT ''
 	);`
 
 	sqlCreateIndexPRMessagesPRURL = `CREATE INDEX IF NOT EXISTS idx_pr_messages_pr_url ON pr_messages(pr_url);`
 
 	sqlCreateIndexPRMessagesInsertedAt = `CREATE INDEX IF NOT EXISTS idx_pr_messages_inserted_at ON pr_messages(inserted_at);`
 
-	sqlInsertPRMessage = `INSERT INTO pr_messages(pr_url, message_channel, message_timestamp) VALUES(?, ?, ?);`
+	sqlCreateTablePRActivity = `CREATE TABLE IF NOT EXISTS pr_activity (
+		pr_url TEXT PRIMARY KEY,
+		last_activity_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	sqlCreateTableHealth = `CREATE TABLE IF NOT EXISTS health (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		checked_at TIMESTAMP NOT NULL
+	);`
+
+	sqlUpsertHealth = `INSERT INTO health(id, checked_at) VALUES(1, CURRENT_TIMESTAMP)
+		ON CONFLICT(id) DO UPDATE SET checked_at = CURRENT_TIMESTAMP;`
+
+	sqlCreateTableDeliveries = `CREATE TABLE IF NOT EXISTS deliveries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		received_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		source TEXT NOT NULL,
+		delivery_id TEXT NOT NULL,
+		event_type TEXT NOT NULL,
+		headers TEXT NOT NULL,
+		body BLOB NOT NULL
+	);`
+
+	sqlCreateIndexDeliveriesReceivedAt = `CREATE INDEX IF NOT EXISTS idx_deliveries_received_at ON deliveries(received_at);`
+
+	sqlInsertDelivery = `INSERT INTO deliveries(source, delivery_id, event_type, headers, body) VALUES(?, ?, ?, ?, ?);`
+
+	sqlSelectDeliveryByID = `SELECT id, received_at, source, delivery_id, event_type, headers, body FROM deliveries WHERE id = ?;`
+
+	// Listing leaves out headers and body; fetch a single delivery to see them.
+	sqlSelectDeliveries = `SELECT id, received_at, source, delivery_id, event_type FROM deliveries
+		WHERE (? = '' OR source = ?) ORDER BY id DESC LIMIT ? OFFSET ?;`
+
+	sqlCountDeliveries = `SELECT COUNT(*) FROM deliveries WHERE (? = '' OR source = ?);`
+
+	sqlDeleteDeliveriesOlderThanDate = `DELETE FROM deliveries WHERE date(received_at) < date(?);`
+
+	sqlCountDeliveriesOlderThanDate = `SELECT COUNT(*) FROM deliveries WHERE date(received_at) < date(?);`
+
+	sqlCreateTableFailedReactions = `CREATE TABLE IF NOT EXISTS failed_reactions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		pr_url TEXT NOT NULL,
+		channel TEXT NOT NULL,
+		ts TEXT NOT NULL,
+		emoji TEXT NOT NULL,
+		error TEXT NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 1,
+		team_id TEXT NOT NULL DEFAULT '',
+		UNIQUE (channel, ts, emoji)
+	);`
+
+	sqlUpsertFailedReaction = `INSERT INTO failed_reactions(pr_url, team_id, channel, ts, emoji, error) VALUES(?, ?, ?, ?, ?, ?)
+		ON CONFLICT(channel, ts, emoji) DO UPDATE SET
+			error = excluded.error, attempts = attempts + 1, updated_at = CURRENT_TIMESTAMP;`
+
+	sqlSelectFailedReactionByID = `SELECT id, created_at, updated_at, pr_url, team_id, channel, ts, emoji, error, attempts
+		FROM failed_reactions WHERE id = ?;`
+
+	sqlSelectFailedReactions = `SELECT id, created_at, updated_at, pr_url, team_id, channel, ts, emoji, error, attempts
+		FROM failed_reactions ORDER BY id LIMIT ? OFFSET ?;`
+
+	sqlSelectRetryableReactions = `SELECT id, created_at, updated_at, pr_url, team_id, channel, ts, emoji, error, attempts
+		FROM failed_reactions WHERE attempts < ? ORDER BY updated_at, id LIMIT ?;`
+
+	sqlCountFailedReactions = `SELECT COUNT(*) FROM failed_reactions;`
+
+	sqlDeleteFailedReactionByID = `DELETE FROM failed_reactions WHERE id = ?;`
+
+	sqlDeleteFailedReactionsOlderThanDate = `DELETE FROM failed_reactions WHERE date(created_at) < date(?);`
+
+	sqlCountFailedReactionsOlderThanDate = `SELECT COUNT(*) FROM failed_reactions WHERE date(created_at) < date(?);`
 
-	sqlSelectMessagesByPRURL = `SELECT id, inserted_at, pr_url, message_channel, message_timestamp FROM pr_messages WHERE pr_url = ?;`
+	sqlInsertPRMessage = `INSERT INTO pr_messages(pr_url, team_id, message_channel, message_timestamp, thread_ts) VALUES(?, ?, ?, ?, ?);`
+
+	sqlInsertPRMessageIfMissing = `INSERT INTO pr_messages(pr_url, team_id, message_channel, message_timestamp)
+		SELECT ?, ?, ?, ? WHERE NOT EXISTS (
+			SELECT 1 FROM pr_messages WHERE pr_url = ? AND message_channel = ? AND message_timestamp = ?
+		);`
+
+	sqlSelectMessagesByPRURL = `SELECT id, inserted_at, pr_url, team_id, message_channel, message_timestamp FROM pr_messages WHERE pr_url = ?;`
+
+	sqlCreateTablePRReactions = `CREATE TABLE IF NOT EXISTS pr_reactions (
+		pr_url TEXT NOT NULL,
+		emoji TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (pr_url, emoji)
+	);`
 
 	sqlDeleteMessagesByPRURL = `DELETE FROM pr_messages WHERE pr_url = ?;`
 
-	sqlDeleteMessagesOlderThanDate = `DELETE FROM pr_messages WHERE date(inserted_at) < date(?);`
+	sqlDeleteMessagesByPRURLInTeam = `DELETE FROM pr_messages WHERE pr_url = ? AND team_id = ?;`
+
+	sqlDeleteMessageByID = `DELETE FROM pr_messages WHERE id = ?;`
+
+	sqlDeleteMessagesBySlackThread = `DELETE FROM pr_messages WHERE message_channel = ? AND (message_timestamp = ? OR thread_ts = ?);`
+
+	sqlDeleteQueuedReactionsByPRURL = `DELETE FROM queued_reactions WHERE pr_url = ?;`
+
+	sqlDeleteQueuedReactionsByPRURLInTeam = `DELETE FROM queued_reactions WHERE pr_url = ? AND team_id = ?;`
+
+	// Run before the message itself is deleted.
+	sqlDeleteQueuedReactionsByMessageID = `DELETE FROM queued_reactions WHERE EXISTS (
+		SELECT 1 FROM pr_messages m WHERE m.id = ? AND m.pr_url = queued_reactions.pr_url
+		AND m.message_channel = queued_reactions.channel AND m.message_timestamp = queued_reactions.ts);`
+
+	sqlDeleteQueuedReactionsBySlackThread = `DELETE FROM queued_reactions WHERE channel = ? AND (ts = ?
+		OR ts IN (SELECT message_timestamp FROM pr_messages WHERE message_channel = ? AND thread_ts = ?));`
+
+	sqlDeleteQueuedReactionsOlderThanDate = `DELETE FROM queued_reactions WHERE date(created_at) < date(?);`
+
+	sqlCountQueuedReactionsOlderThanDate = `SELECT COUNT(*) FROM queued_reactions WHERE date(created_at) < date(?);`
+
+	// An empty channel matches all channels.
+	sqlSelectTrackedPRs = `SELECT pr_url, COUNT(*), MIN(inserted_at), MAX(inserted_at) FROM pr_messages
+		WHERE (? = '' OR message_channel = ?)
+		GROUP BY pr_url ORDER BY MAX(inserted_at) DESC, pr_url LIMIT ? OFFSET ?;`
+
+	sqlCountTrackedPRs = `SELECT COUNT(DISTINCT pr_url) FROM pr_messages;`
+
+	sqlInsertPRReaction = `INSERT INTO pr_reactions(pr_url, emoji) VALUES(?, ?)
+		ON CONFLICT(pr_url, emoji) DO UPDATE SET applied_at = CURRENT_TIMESTAMP;`
+
+	sqlSelectPRReactions = `SELECT emoji FROM pr_reactions WHERE pr_url = ? ORDER BY applied_at, emoji;`
+
+	sqlDeleteReactionsByPRURL = `DELETE FROM pr_reactions WHERE pr_url = ?;`
+
+	sqlDeleteUntrackedReactionsByPRURL = `DELETE FROM pr_reactions WHERE pr_url = ?
+		AND NOT EXISTS (SELECT 1 FROM pr_messages WHERE pr_url = ?);`
+
+	sqlDeleteOrphanedReactions = `DELETE FROM pr_reactions WHERE pr_url NOT IN (SELECT pr_url FROM pr_messages);`
+
+	sqlCountOrphanedReactions = `SELECT COUNT(*) FROM pr_reactions WHERE pr_url NOT IN (SELECT pr_url FROM pr_messages);`
+
+	sqlSelectPRActivity = `SELECT last_activity_at FROM pr_activity WHERE pr_url = ?;`
+
+	sqlDeleteActivityByPRURL = `DELETE FROM pr_activity WHERE pr_url = ?;`
+
+	sqlDeleteUntrackedActivityByPRURL = `DELETE FROM pr_activity WHERE pr_url = ?
+		AND NOT EXISTS (SELECT 1 FROM pr_messages WHERE pr_url = ?);`
+
+	// Only tracked PRs get an activity row; org-wide webhooks would otherwise fill the table.
+	sqlTouchPRActivity = `INSERT INTO pr_activity(pr_url)
+		SELECT ? WHERE EXISTS (SELECT 1 FROM pr_messages WHERE pr_url = ?)
+		ON CONFLICT(pr_url) DO UPDATE SET last_activity_at = CURRENT_TIMESTAMP;`
+
+	// The retention queries below are templates: %[1]s is replaced by a ChannelScope condition.
+	sqlDeleteMessagesOlderThanDate = `DELETE FROM pr_messages WHERE %[1]s AND date(inserted_at) < date(?);`
+
+	sqlCountMessagesOlderThanDate = `SELECT COUNT(*) FROM pr_messages WHERE %[1]s AND date(inserted_at) < date(?);`
+
+	// A PR is inactive when neither its newest message in scope nor its last GitHub event is on or after the cutoff date.
+	sqlSelectInactivePRURLs = `SELECT m.pr_url FROM pr_messages m
+		LEFT JOIN pr_activity a ON a.pr_url = m.pr_url
+		WHERE %[1]s
+		GROUP BY m.pr_url
+		HAVING date(MAX(MAX(m.inserted_at), COALESCE(MAX(a.last_activity_at), ''))) < date(?)`
+
+	sqlDeleteMessagesInactiveBeforeDate = `DELETE FROM pr_messages WHERE %[1]s AND pr_url IN (` + sqlSelectInactivePRURLs + `);`
+
+	sqlCountMessagesInactiveBeforeDate = `SELECT COUNT(*) FROM pr_messages WHERE %[1]s AND pr_url IN (` + sqlSelectInactivePRURLs + `);`
+
+	sqlCreateTableSlackInstallations = `CREATE TABLE IF NOT EXISTS slack_installations (
+		team_id TEXT PRIMARY KEY,
+		team_name TEXT NOT NULL,
+		bot_user_id TEXT NOT NULL,
+		bot_token TEXT NOT NULL,
+		scope TEXT NOT NULL,
+		installed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	sqlUpsertSlackInstallation = `INSERT INTO slack_installations(team_id, team_name, bot_user_id, bot_token, scope) VALUES(?, ?, ?, ?, ?)
+		ON CONFLICT(team_id) DO UPDATE SET team_name = excluded.team_name, bot_user_id = excluded.bot_user_id,
+			bot_token = excluded.bot_token, scope = excluded.scope, updated_at = CURRENT_TIMESTAMP;`
+
+	sqlSelectSlackInstallationByTeam = `SELECT team_id, team_name, bot_user_id, bot_token, scope, installed_at, updated_at
+		FROM slack_installations WHERE team_id = ?;`
+
+	sqlSelectSlackInstallations = `SELECT team_id, team_name, bot_user_id, bot_token, scope, installed_at, updated_at
+		FROM slack_installations ORDER BY installed_at, team_id;`
+
+	sqlDeleteSlackInstallation = `DELETE FROM slack_installations WHERE team_id = ?;`
+
+	sqlDeleteMessagesByTeam        = `DELETE FROM pr_messages WHERE team_id = ?;`
+	sqlDeleteFailedReactionsByTeam = `DELETE FROM failed_reactions WHERE team_id = ?;`
+	sqlDeleteQueuedReactionsByTeam = `DELETE FROM queued_reactions WHERE team_id = ?;`
+
+	// Rows written before messages were scoped to a workspace have an empty team_id.
+	sqlAssignLegacyTeamMessages        = `UPDATE pr_messages SET team_id = ? WHERE team_id = '';`
+	sqlAssignLegacyTeamFailedReactions = `UPDATE failed_reactions SET team_id = ? WHERE team_id = '';`
+	sqlAssignLegacyTeamQueuedReactions = `UPDATE queued_reactions SET team_id = ? WHERE team_id = '';`
+
+	// due_at is a Unix timestamp so that it compares as a number.
+	sqlCreateTableQueuedReactions = `CREATE TABLE IF NOT EXISTS queued_reactions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		pr_url TEXT NOT NULL,
+		team_id TEXT NOT NULL,
+		channel TEXT NOT NULL,
+		ts TEXT NOT NULL,
+		emoji TEXT NOT NULL,
+		due_at INTEGER NOT NULL,
+		UNIQUE (channel, ts, emoji)
+	);`
+
+	sqlCreateIndexQueuedReactionsDueAt = `CREATE INDEX IF NOT EXISTS idx_queued_reactions_due_at ON queued_reactions(due_at);`
+
+	sqlUpsertQueuedReaction = `INSERT INTO queued_reactions(pr_url, team_id, channel, ts, emoji, due_at) VALUES(?, ?, ?, ?, ?, ?)
+		ON CONFLICT(channel, ts, emoji) DO UPDATE SET due_at = excluded.due_at;`
+
+	sqlSelectDueReactions = `SELECT id, created_at, pr_url, team_id, channel, ts, emoji, due_at
+		FROM queued_reactions WHERE due_at <= ? ORDER BY due_at, id LIMIT ?;`
+
+	sqlCountQueuedReactions = `SELECT COUNT(*) FROM queued_reactions;`
+
+	sqlDeleteQueuedReactionByID = `DELETE FROM queued_reactions WHERE id = ?;`
+
+	sqlDeleteQueuedReactionsByPRURLAndEmoji = `DELETE FROM queued_reactions WHERE pr_url = ? AND emoji = ?;`
+
+	sqlCountColumn = `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?;`
+
+	sqlDeleteOrphanedActivity = `DELETE FROM pr_activity WHERE pr_url NOT IN (SELECT pr_url FROM pr_messages);`
+
+	sqlCountOrphanedActivity = `SELECT COUNT(*) FROM pr_activity WHERE pr_url NOT IN (SELECT pr_url FROM pr_messages);`
 )
 
+const (
+	// TablePRMessages is the name of the table holding PR URL → Slack message mappings.
+	TablePRMessages = "pr_messages"
+	// TablePRActivity is the name of the table holding the last GitHub activity per tracked PR.
+	TablePRActivity = "pr_activity"
+	// TablePRReactions is the name of the table holding the emoji applied per tracked PR.
+	TablePRReactions = "pr_reactions"
+	// TableDeliveries is the name of the table holding archived raw webhook payloads.
+	TableDeliveries = "deliveries"
+	// TableFailedReactions is the name of the table holding reactions that Slack rejected.
+	TableFailedReactions = "failed_reactions"
+	// TableQueuedReactions is the name of the table holding reactions held back by debouncing or quiet hours.
+	TableQueuedReactions = "queued_reactions"
+	// TableSlackInstallations is the name of the table holding the bot token per Slack workspace.
+	TableSlackInstallations = "slack_installations"
+)
+
+// addedColumns are columns added to a table after it was first released. initSchema adds
+// them to databases created before.
+var addedColumns = []struct{ table, column, decl string }{
+	{TablePRMessages, "team_id", "TEXT NOT NULL DEFAULT ''"},
+	{TablePRMessages, "thread_ts", "TEXT NOT NULL DEFAULT ''"},
+	{TableFailedReactions, "team_id", "TEXT NOT NULL DEFAULT ''"},
+}
+
+// ErrNotFound is returned when a row looked up by ID doesn't exist.
+var ErrNotFound = errors.New("not found")
+
+// Delivery is an archived raw webhook payload.
+type Delivery struct {
+	ID         int64               `json:"id"`
+	ReceivedAt time.Time           `json:"received_at"`
+	Source     string              `json:"source"`
+	DeliveryID string              `json:"delivery_id"`
+	EventType  string              `json:"event_type"`
+	Headers    map[string][]string `json:"headers,omitempty"`
+	Body       []byte              `json:"-"`
+}
+
+// FailedReaction is a reaction that could not be added, kept for retrying.
+type FailedReaction struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	PRURL     string    `json:"pr_url"`
+	TeamID    string    `json:"team_id,omitempty"`
+	Channel   string    `json:"channel"`
+	TS        string    `json:"ts"`
+	Emoji     string    `json:"emoji"`
+	Error     string    `json:"error"`
+	Attempts  int       `json:"attempts"`
+}
+
+// QueuedReaction is a reaction to add once DueAt has passed.
+type QueuedReaction struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	PRURL     string    `json:"pr_url"`
+	TeamID    string    `json:"team_id,omitempty"`
+	Channel   string    `json:"channel"`
+	TS        string    `json:"ts"`
+	Emoji     string    `json:"emoji"`
+	DueAt     time.Time `json:"due_at"`
+}
+
 type Message struct {
-	ID               int64
-	InsertedAt       time.Time
-	PRURL            string
-	MessageChannel   string
-	MessageTimestamp string
+	ID               int64     `json:"id"`
+	InsertedAt       time.Time `json:"inserted_at"`
+	PRURL            string    `json:"pr_url"`
+	TeamID           string    `json:"team_id,omitempty"`
+	MessageChannel   string    `json:"channel"`
+	MessageTimestamp string    `json:"ts"`
+}
+
+// TrackedPR summarizes the messages stored for one PR URL.
+type TrackedPR struct {
+	PRURL        string    `json:"pr_url"`
+	MessageCount int       `json:"message_count"`
+	FirstSeenAt  time.Time `json:"first_seen_at"`
+	LastSeenAt   time.Time `json:"last_seen_at"`
+}
+
+// SlackInstallation is a Slack workspace prmoji was installed to through OAuth.
+type SlackInstallation struct {
+	TeamID      string    `json:"team_id"`
+	TeamName    string    `json:"team_name"`
+	BotUserID   string    `json:"bot_user_id"`
+	BotToken    string    `json:"-"`
+	Scope       string    `json:"scope"`
+	InstalledAt time.Time `json:"installed_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
 }
 
 type SQLiteStore struct {
@@ -63,33 +376,95 @@ func NewSQLiteStore(dbPath string) (*SQLiteStore, error) {
 	return s, nil
 }
 
+// inTx runs fn in a transaction, committing it if fn returns nil.
+func (s *SQLiteStore) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	return nil
+}
+
 func (s *SQLiteStore) Close() error {
 	return s.db.Close()
 }
 
+// Ping checks that the database is reachable and writable.
+func (s *SQLiteStore) Ping(ctx context.Context) error {
+	defer metrics.ObserveStoreQuery("ping", time.Now())
+	if err := s.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("ping: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, sqlUpsertHealth); err != nil {
+		return fmt.Errorf("write health row: %w", err)
+	}
+	return nil
+}
+
 func (s *SQLiteStore) initSchema(ctx context.Context) error {
 	stmts := []string{
 		sqlCreateTablePRMessages,
 		sqlCreateIndexPRMessagesPRURL,
 		sqlCreateIndexPRMessagesInsertedAt,
+		sqlCreateTablePRActivity,
+		sqlCreateTablePRReactions,
+		sqlCreateTableHealth,
+		sqlCreateTableDeliveries,
+		sqlCreateIndexDeliveriesReceivedAt,
+		sqlCreateTableFailedReactions,
+		sqlCreateTableSlackInstallations,
+		sqlCreateTableQueuedReactions,
+		sqlCreateIndexQueuedReactionsDueAt,
 	}
 	for _, stmt := range stmts {
 		if _, err := s.db.ExecContext(ctx, stmt); err != nil {
 			return fmt.Errorf("init schema: %w", err)
 		}
 	}
-	slog.Info("sqlite schema initialized")
+	for _, c := range addedColumns {
+		var n int
+		if err := s.db.QueryRowContext(ctx, sqlCountColumn, c.table, c.column).Scan(&n); err != nil {
+			return fmt.Errorf("init schema: %w", err)
+		}
+		if n > 0 {
+			continue
+		}
+		if _, err := s.db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", c.table, c.column, c.decl)); err != nil {
+			return fmt.Errorf("init schema: add %s.%s: %w", c.table, c.column, err)
+		}
+		slog.InfoContext(ctx, "added column", "table", c.table, "column", c.column)
+	}
+	slog.InfoContext(ctx, "sqlite schema initialized")
 	return nil
 }
 
-func (s *SQLiteStore) InsertPRMessage(ctx context.Context, prURL, channel, ts string) error {
-	slog.Debug("inserting pr message", "pr_url", prURL, "channel", channel, "ts", ts)
-	_, err := s.db.ExecContext(
+// InsertPRMessage tracks prURL for the message ts in channel of the Slack workspace teamID.
+func (s *SQLiteStore) InsertPRMessage(ctx context.Context, prURL, teamID, channel, ts string) error {
+	return s.InsertPRThreadReply(ctx, prURL, teamID, channel, ts, "")
+}
+
+// InsertPRThreadReply is InsertPRMessage for a reply in the thread of the message threadTS, so
+// that DeleteSlackThread finds it. An empty threadTS is a top-level message.
+func (s *SQLiteStore) InsertPRThreadReply(ctx context.Context, prURL, teamID, channel, ts, threadTS string) (err error) {
+	defer metrics.ObserveStoreQuery("insert_pr_message", time.Now())
+	ctx, span := tracing.Start(ctx, "store.InsertPRMessage", attribute.String("pr_url", prURL))
+	defer func() { endSpan(span, err) }()
+	slog.DebugContext(ctx, "inserting pr message", "pr_url", prURL, "team_id", teamID, "channel", channel, "ts", ts, "thread_ts", threadTS)
+	_, err = s.db.ExecContext(
 		ctx,
 		sqlInsertPRMessage,
 		prURL,
+		teamID,
 		channel,
 		ts,
+		threadTS,
 	)
 	if err != nil {
 		return fmt.Errorf("insert pr message: %w", err)
@@ -97,7 +472,22 @@ func (s *SQLiteStore) InsertPRMessage(ctx context.Context, prURL, channel, ts st
 	return nil
 }
 
-func (s *SQLiteStore) ListMessagesByPRURL(ctx context.Context, prURL string) ([]Message, error) {
+// TrackPRMessage is InsertPRMessage for callers that may see the same message twice: it only
+// inserts a mapping that doesn't exist yet and reports whether it did.
+func (s *SQLiteStore) TrackPRMessage(ctx context.Context, prURL, teamID, channel, ts string) (bool, error) {
+	defer metrics.ObserveStoreQuery("track_pr_message", time.Now())
+	res, err := s.db.ExecContext(ctx, sqlInsertPRMessageIfMissing, prURL, teamID, channel, ts, prURL, channel, ts)
+	if err != nil {
+		return false, fmt.Errorf("track pr message: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+func (s *SQLiteStore) ListMessagesByPRURL(ctx context.Context, prURL string) (_ []Message, err error) {
+	defer metrics.ObserveStoreQuery("list_messages_by_pr_url", time.Now())
+	ctx, span := tracing.Start(ctx, "store.ListMessagesByPRURL", attribute.String("pr_url", prURL))
+	defer func() { endSpan(span, err) }()
 	rows, err := s.db.QueryContext(ctx,
 		sqlSelectMessagesByPRURL,
 		prURL,
@@ -110,7 +500,7 @@ func (s *SQLiteStore) ListMessagesByPRURL(ctx context.Context, prURL string) ([]
 	var out []Message
 	for rows.Next() {
 		var m Message
-		if err := rows.Scan(&m.ID, &m.InsertedAt, &m.PRURL, &m.MessageChannel, &m.MessageTimestamp); err != nil {
+		if err := rows.Scan(&m.ID, &m.InsertedAt, &m.PRURL, &m.TeamID, &m.MessageChannel, &m.MessageTimestamp); err != nil {
 			return nil, fmt.Errorf("scan message: %w", err)
 		}
 		out = append(out, m)
@@ -118,26 +508,740 @@ func (s *SQLiteStore) ListMessagesByPRURL(ctx context.Context, prURL string) ([]
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("rows: %w", err)
 	}
-	slog.Debug("listed messages by pr_url", "pr_url", prURL, "count", len(out))
+	slog.DebugContext(ctx, "listed messages by pr_url", "pr_url", prURL, "count", len(out))
+	return out, nil
+}
+
+func (s *SQLiteStore) DeleteByPRURL(ctx context.Context, prURL string) (err error) {
+	defer metrics.ObserveStoreQuery("delete_by_pr_url", time.Now())
+	ctx, span := tracing.Start(ctx, "store.DeleteByPRURL", attribute.String("pr_url", prURL))
+	defer func() { endSpan(span, err) }()
+	slog.DebugContext(ctx, "deleting messages by pr_url", "pr_url", prURL)
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, sqlDeleteMessagesByPRURL, prURL); err != nil {
+			return fmt.Errorf("delete by pr_url: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, sqlDeleteActivityByPRURL, prURL); err != nil {
+			return fmt.Errorf("delete activity by pr_url: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, sqlDeleteReactionsByPRURL, prURL); err != nil {
+			return fmt.Errorf("delete reactions by pr_url: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, sqlDeleteQueuedReactionsByPRURL, prURL); err != nil {
+			return fmt.Errorf("delete queued reactions by pr_url: %w", err)
+		}
+		return nil
+	})
+}
+
+// DeleteByPRURLInTeam deletes the messages tracking prURL in one Slack workspace and returns how many
+// there were, along with the reactions queued on them. The PR's activity and reactions go too once
+// no workspace tracks it.
+func (s *SQLiteStore) DeleteByPRURLInTeam(ctx context.Context, prURL, teamID string) (n int64, err error) {
+	defer metrics.ObserveStoreQuery("delete_by_pr_url_in_team", time.Now())
+	ctx, span := tracing.Start(ctx, "store.DeleteByPRURLInTeam", attribute.String("pr_url", prURL), attribute.String("team_id", teamID))
+	defer func() { endSpan(span, err) }()
+	slog.DebugContext(ctx, "deleting messages by pr_url in team", "pr_url", prURL, "team_id", teamID)
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, sqlDeleteMessagesByPRURLInTeam, prURL, teamID)
+		if err != nil {
+			return fmt.Errorf("delete by pr_url in team: %w", err)
+		}
+		if n, err = res.RowsAffected(); err != nil {
+			return fmt.Errorf("rows affected: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, sqlDeleteQueuedReactionsByPRURLInTeam, prURL, teamID); err != nil {
+			return fmt.Errorf("delete queued reactions by pr_url in team: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, sqlDeleteUntrackedActivityByPRURL, prURL, prURL); err != nil {
+			return fmt.Errorf("delete activity by pr_url: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, sqlDeleteUntrackedReactionsByPRURL, prURL, prURL); err != nil {
+			return fmt.Errorf("delete reactions by pr_url: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// DeleteMessage deletes a single message and the reactions queued on it, and reports whether
+// it existed.
+func (s *SQLiteStore) DeleteMessage(ctx context.Context, id int64) (found bool, err error) {
+	defer metrics.ObserveStoreQuery("delete_message", time.Now())
+	slog.DebugContext(ctx, "deleting message", "id", id)
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, sqlDeleteQueuedReactionsByMessageID, id); err != nil {
+			return fmt.Errorf("delete queued reactions by message: %w", err)
+		}
+		res, err := tx.ExecContext(ctx, sqlDeleteMessageByID, id)
+		if err != nil {
+			return fmt.Errorf("delete message: %w", err)
+		}
+		n, _ := res.RowsAffected()
+		found = n > 0
+		return nil
+	})
+	return found, err
+}
+
+// DeleteSlackThread stops tracking every PR attached to the Slack message channel/threadTS or
+// to a reply in its thread, drops the reactions queued on them, and returns how many mappings
+// were removed.
+func (s *SQLiteStore) DeleteSlackThread(ctx context.Context, channel, threadTS string) (n int64, err error) {
+	defer metrics.ObserveStoreQuery("delete_slack_thread", time.Now())
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, sqlDeleteQueuedReactionsBySlackThread, channel, threadTS, channel, threadTS); err != nil {
+			return fmt.Errorf("delete queued reactions by slack thread: %w", err)
+		}
+		res, err := tx.ExecContext(ctx, sqlDeleteMessagesBySlackThread, channel, threadTS, threadTS)
+		if err != nil {
+			return fmt.Errorf("delete slack thread: %w", err)
+		}
+		n, _ = res.RowsAffected()
+		return nil
+	})
+	return n, err
+}
+
+// ListTrackedPRs returns one page of tracked PRs, most recently posted first.
+func (s *SQLiteStore) ListTrackedPRs(ctx context.Context, limit, offset int) ([]TrackedPR, error) {
+	defer metrics.ObserveStoreQuery("list_tracked_prs", time.Now())
+	return s.listTrackedPRs(ctx, "", limit, offset)
+}
+
+// ListTrackedPRsInChannel is ListTrackedPRs restricted to PRs posted in channel; MessageCount
+// only counts that channel's messages.
+func (s *SQLiteStore) ListTrackedPRsInChannel(ctx context.Context, channel string, limit, offset int) ([]TrackedPR, error) {
+	defer metrics.ObserveStoreQuery("list_tracked_prs_in_channel", time.Now())
+	return s.listTrackedPRs(ctx, channel, limit, offset)
+}
+
+func (s *SQLiteStore) listTrackedPRs(ctx context.Context, channel string, limit, offset int) ([]TrackedPR, error) {
+	rows, err := s.db.QueryContext(ctx, sqlSelectTrackedPRs, channel, channel, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("list tracked prs: %w", err)
+	}
+	defer rows.Close()
+
+	var out []TrackedPR
+	for rows.Next() {
+		var (
+			pr          TrackedPR
+			first, last string
+		)
+		// MIN/MAX lose the column's declared type, so the driver hands back text.
+		if err := rows.Scan(&pr.PRURL, &pr.MessageCount, &first, &last); err != nil {
+			return nil, fmt.Errorf("scan tracked pr: %w", err)
+		}
+		pr.FirstSeenAt = parseTimestamp(first)
+		pr.LastSeenAt = parseTimestamp(last)
+		out = append(out, pr)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
 	return out, nil
 }
 
-func (s *SQLiteStore) DeleteByPRURL(ctx context.Context, prURL string) error {
-	slog.Debug("deleting messages by pr_url", "pr_url", prURL)
-	_, err := s.db.ExecContext(ctx, sqlDeleteMessagesByPRURL, prURL)
+// CountTrackedPRs returns the number of distinct tracked PR URLs.
+func (s *SQLiteStore) CountTrackedPRs(ctx context.Context) (int64, error) {
+	defer metrics.ObserveStoreQuery("count_tracked_prs", time.Now())
+	var n int64
+	if err := s.db.QueryRowContext(ctx, sqlCountTrackedPRs).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count tracked prs: %w", err)
+	}
+	return n, nil
+}
+
+// InsertPRReaction records that emoji was applied for prURL so it can be re-applied later.
+func (s *SQLiteStore) InsertPRReaction(ctx context.Context, prURL, emoji string) error {
+	defer metrics.ObserveStoreQuery("insert_pr_reaction", time.Now())
+	if _, err := s.db.ExecContext(ctx, sqlInsertPRReaction, prURL, emoji); err != nil {
+		return fmt.Errorf("insert pr reaction: %w", err)
+	}
+	return nil
+}
+
+// ListPRReactions returns the emoji recorded for prURL in the order they were applied.
+func (s *SQLiteStore) ListPRReactions(ctx context.Context, prURL string) ([]string, error) {
+	defer metrics.ObserveStoreQuery("list_pr_reactions", time.Now())
+	rows, err := s.db.QueryContext(ctx, sqlSelectPRReactions, prURL)
 	if err != nil {
-		return fmt.Errorf("delete by pr_url: %w", err)
+		return nil, fmt.Errorf("list pr reactions: %w", err)
+	}
+	defer rows.Close()
+
+	var out []string
+	for rows.Next() {
+		var emoji string
+		if err := rows.Scan(&emoji); err != nil {
+			return nil, fmt.Errorf("scan pr reaction: %w", err)
+		}
+		out = append(out, emoji)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+	return out, nil
+}
+
+// GetPRActivity returns the time of the last GitHub event seen for a tracked PR, or ErrNotFound.
+func (s *SQLiteStore) GetPRActivity(ctx context.Context, prURL string) (time.Time, error) {
+	defer metrics.ObserveStoreQuery("get_pr_activity", time.Now())
+	var at time.Time
+	err := s.db.QueryRowContext(ctx, sqlSelectPRActivity, prURL).Scan(&at)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, ErrNotFound
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("get pr activity: %w", err)
+	}
+	return at, nil
+}
+
+// TouchPRActivity records GitHub activity for prURL now. It is a no-op for PRs that are not tracked.
+func (s *SQLiteStore) TouchPRActivity(ctx context.Context, prURL string) error {
+	defer metrics.ObserveStoreQuery("touch_pr_activity", time.Now())
+	slog.DebugContext(ctx, "touching pr activity", "pr_url", prURL)
+	if _, err := s.db.ExecContext(ctx, sqlTouchPRActivity, prURL, prURL); err != nil {
+		return fmt.Errorf("touch pr activity: %w", err)
 	}
 	return nil
 }
 
-// DeleteOlderThanDate deletes rows whose inserted_at date is strictly older than cutoffDate (date-only compare).
-func (s *SQLiteStore) DeleteOlderThanDate(ctx context.Context, cutoffDate time.Time) (int64, error) {
-	cutoff := cutoffDate.UTC().Format("2006-01-02")
-	res, err := s.db.ExecContext(ctx, sqlDeleteMessagesOlderThanDate, cutoff)
+// ChannelScope restricts a retention query to a single channel, or to every channel
+// except the excluded ones when Channel is empty.
+type ChannelScope struct {
+	Channel string
+	Exclude []string
+}
+
+func (c ChannelScope) condition() (string, []any) {
+	if c.Channel != "" {
+		return "message_channel = ?", []any{c.Channel}
+	}
+	if len(c.Exclude) == 0 {
+		return "1 = 1", nil
+	}
+	args := make([]any, 0, len(c.Exclude))
+	for _, ch := range c.Exclude {
+		args = append(args, ch)
+	}
+	return "COALESCE(message_channel, '') NOT IN (?" + strings.Repeat(", ?", len(c.Exclude)-1) + ")", args
+}
+
+// retentionQuery fills the scope condition into a retention query template. inScopeTwice is set for
+// the inactivity queries, whose subquery repeats the scope condition before the cutoff argument.
+func retentionQuery(tmpl string, scope ChannelScope, cutoffDate time.Time, inScopeTwice bool) (string, []any) {
+	cond, scopeArgs := scope.condition()
+	args := append([]any{}, scopeArgs...)
+	if inScopeTwice {
+		args = append(args, scopeArgs...)
+	}
+	args = append(args, cutoffDate.UTC().Format("2006-01-02"))
+	return fmt.Sprintf(tmpl, cond), args
+}
+
+// DeleteOlderThanDate deletes rows in scope whose inserted_at date is strictly older than cutoffDate (date-only compare).
+func (s *SQLiteStore) DeleteOlderThanDate(ctx context.Context, cutoffDate time.Time, scope ChannelScope) (int64, error) {
+	defer metrics.ObserveStoreQuery("delete_older_than_date", time.Now())
+	q, args := retentionQuery(sqlDeleteMessagesOlderThanDate, scope, cutoffDate, false)
+	res, err := s.db.ExecContext(ctx, q, args...)
 	if err != nil {
 		return 0, fmt.Errorf("delete older than: %w", err)
 	}
 	n, _ := res.RowsAffected()
 	return n, nil
 }
+
+// CountOlderThanDate counts rows that DeleteOlderThanDate would delete for the same cutoffDate and scope.
+func (s *SQLiteStore) CountOlderThanDate(ctx context.Context, cutoffDate time.Time, scope ChannelScope) (int64, error) {
+	defer metrics.ObserveStoreQuery("count_older_than_date", time.Now())
+	q, args := retentionQuery(sqlCountMessagesOlderThanDate, scope, cutoffDate, false)
+	var n int64
+	if err := s.db.QueryRowContext(ctx, q, args...).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count older than: %w", err)
+	}
+	return n, nil
+}
+
+// DeleteInactiveBeforeDate deletes the in-scope messages of PRs whose last activity (newest in-scope
+// message or GitHub event) is strictly older than cutoffDate (date-only compare).
+func (s *SQLiteStore) DeleteInactiveBeforeDate(ctx context.Context, cutoffDate time.Time, scope ChannelScope) (int64, error) {
+	defer metrics.ObserveStoreQuery("delete_inactive_before_date", time.Now())
+	q, args := retentionQuery(sqlDeleteMessagesInactiveBeforeDate, scope, cutoffDate, true)
+	res, err := s.db.ExecContext(ctx, q, args...)
+	if err != nil {
+		return 0, fmt.Errorf("delete inactive before: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n, nil
+}
+
+// CountInactiveBeforeDate counts rows that DeleteInactiveBeforeDate would delete for the same cutoffDate and scope.
+func (s *SQLiteStore) CountInactiveBeforeDate(ctx context.Context, cutoffDate time.Time, scope ChannelScope) (int64, error) {
+	defer metrics.ObserveStoreQuery("count_inactive_before_date", time.Now())
+	q, args := retentionQuery(sqlCountMessagesInactiveBeforeDate, scope, cutoffDate, true)
+	var n int64
+	if err := s.db.QueryRowContext(ctx, q, args...).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count inactive before: %w", err)
+	}
+	return n, nil
+}
+
+// InsertDelivery archives a raw webhook payload and returns its ID.
+func (s *SQLiteStore) InsertDelivery(ctx context.Context, d Delivery) (int64, error) {
+	defer metrics.ObserveStoreQuery("insert_delivery", time.Now())
+	headers, err := json.Marshal(d.Headers)
+	if err != nil {
+		return 0, fmt.Errorf("encode headers: %w", err)
+	}
+	res, err := s.db.ExecContext(ctx, sqlInsertDelivery, d.Source, d.DeliveryID, d.EventType, string(headers), d.Body)
+	if err != nil {
+		return 0, fmt.Errorf("insert delivery: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+// GetDelivery returns an archived payload including headers and body, or ErrNotFound.
+func (s *SQLiteStore) GetDelivery(ctx context.Context, id int64) (Delivery, error) {
+	defer metrics.ObserveStoreQuery("get_delivery", time.Now())
+	var (
+		d       Delivery
+		headers string
+	)
+	err := s.db.QueryRowContext(ctx, sqlSelectDeliveryByID, id).
+		Scan(&d.ID, &d.ReceivedAt, &d.Source, &d.DeliveryID, &d.EventType, &headers, &d.Body)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Delivery{}, ErrNotFound
+	}
+	if err != nil {
+		return Delivery{}, fmt.Errorf("get delivery: %w", err)
+	}
+	if err := json.Unmarshal([]byte(headers), &d.Headers); err != nil {
+		return Delivery{}, fmt.Errorf("decode headers: %w", err)
+	}
+	return d, nil
+}
+
+// ListDeliveries returns one page of archived payloads without headers and body, newest first.
+// An empty source matches all sources.
+func (s *SQLiteStore) ListDeliveries(ctx context.Context, source string, limit, offset int) ([]Delivery, error) {
+	defer metrics.ObserveStoreQuery("list_deliveries", time.Now())
+	rows, err := s.db.QueryContext(ctx, sqlSelectDeliveries, source, source, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("list deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var out []Delivery
+	for rows.Next() {
+		var d Delivery
+		if err := rows.Scan(&d.ID, &d.ReceivedAt, &d.Source, &d.DeliveryID, &d.EventType); err != nil {
+			return nil, fmt.Errorf("scan delivery: %w", err)
+		}
+		out = append(out, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+	return out, nil
+}
+
+// CountDeliveries counts archived payloads; an empty source matches all sources.
+func (s *SQLiteStore) CountDeliveries(ctx context.Context, source string) (int64, error) {
+	defer metrics.ObserveStoreQuery("count_deliveries", time.Now())
+	var n int64
+	if err := s.db.QueryRowContext(ctx, sqlCountDeliveries, source, source).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count deliveries: %w", err)
+	}
+	return n, nil
+}
+
+// DeleteDeliveriesOlderThanDate deletes archived payloads received strictly before cutoffDate (date-only compare).
+func (s *SQLiteStore) DeleteDeliveriesOlderThanDate(ctx context.Context, cutoffDate time.Time) (int64, error) {
+	defer metrics.ObserveStoreQuery("delete_deliveries_older_than_date", time.Now())
+	res, err := s.db.ExecContext(ctx, sqlDeleteDeliveriesOlderThanDate, cutoffDate.UTC().Format("2006-01-02"))
+	if err != nil {
+		return 0, fmt.Errorf("delete deliveries older than: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n, nil
+}
+
+// CountDeliveriesOlderThanDate counts rows that DeleteDeliveriesOlderThanDate would delete.
+func (s *SQLiteStore) CountDeliveriesOlderThanDate(ctx context.Context, cutoffDate time.Time) (int64, error) {
+	defer metrics.ObserveStoreQuery("count_deliveries_older_than_date", time.Now())
+	var n int64
+	if err := s.db.QueryRowContext(ctx, sqlCountDeliveriesOlderThanDate, cutoffDate.UTC().Format("2006-01-02")).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count deliveries older than: %w", err)
+	}
+	return n, nil
+}
+
+// RecordFailedReaction stores a failed attempt to add a reaction. Repeated failures for the same
+// message and emoji increment its attempt count.
+func (s *SQLiteStore) RecordFailedReaction(ctx context.Context, fr FailedReaction) error {
+	defer metrics.ObserveStoreQuery("record_failed_reaction", time.Now())
+	if _, err := s.db.ExecContext(ctx, sqlUpsertFailedReaction, fr.PRURL, fr.TeamID, fr.Channel, fr.TS, fr.Emoji, fr.Error); err != nil {
+		return fmt.Errorf("record failed reaction: %w", err)
+	}
+	return nil
+}
+
+// GetFailedReaction returns one failed reaction, or ErrNotFound.
+func (s *SQLiteStore) GetFailedReaction(ctx context.Context, id int64) (FailedReaction, error) {
+	defer metrics.ObserveStoreQuery("get_failed_reaction", time.Now())
+	fr, err := scanFailedReaction(s.db.QueryRowContext(ctx, sqlSelectFailedReactionByID, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return FailedReaction{}, ErrNotFound
+	}
+	if err != nil {
+		return FailedReaction{}, fmt.Errorf("get failed reaction: %w", err)
+	}
+	return fr, nil
+}
+
+// ListFailedReactions returns one page of failed reactions, oldest first.
+func (s *SQLiteStore) ListFailedReactions(ctx context.Context, limit, offset int) ([]FailedReaction, error) {
+	defer metrics.ObserveStoreQuery("list_failed_reactions", time.Now())
+	return s.queryFailedReactions(ctx, sqlSelectFailedReactions, limit, offset)
+}
+
+// ListRetryableReactions returns up to limit failed reactions with fewer than maxAttempts attempts,
+// least recently tried first.
+func (s *SQLiteStore) ListRetryableReactions(ctx context.Context, maxAttempts, limit int) ([]FailedReaction, error) {
+	defer metrics.ObserveStoreQuery("list_retryable_reactions", time.Now())
+	return s.queryFailedReactions(ctx, sqlSelectRetryableReactions, maxAttempts, limit)
+}
+
+func (s *SQLiteStore) queryFailedReactions(ctx context.Context, query string, args ...any) ([]FailedReaction, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list failed reactions: %w", err)
+	}
+	defer rows.Close()
+
+	var out []FailedReaction
+	for rows.Next() {
+		fr, err := scanFailedReaction(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan failed reaction: %w", err)
+		}
+		out = append(out, fr)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+	return out, nil
+}
+
+func scanFailedReaction(row interface{ Scan(...any) error }) (FailedReaction, error) {
+	var fr FailedReaction
+	err := row.Scan(&fr.ID, &fr.CreatedAt, &fr.UpdatedAt, &fr.PRURL, &fr.TeamID, &fr.Channel, &fr.TS, &fr.Emoji, &fr.Error, &fr.Attempts)
+	return fr, err
+}
+
+// CountFailedReactions counts all failed reactions, including those no longer retried.
+func (s *SQLiteStore) CountFailedReactions(ctx context.Context) (int64, error) {
+	defer metrics.ObserveStoreQuery("count_failed_reactions", time.Now())
+	var n int64
+	if err := s.db.QueryRowContext(ctx, sqlCountFailedReactions).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count failed reactions: %w", err)
+	}
+	return n, nil
+}
+
+// DeleteFailedReaction removes a failed reaction and reports whether it existed.
+func (s *SQLiteStore) DeleteFailedReaction(ctx context.Context, id int64) (bool, error) {
+	defer metrics.ObserveStoreQuery("delete_failed_reaction", time.Now())
+	res, err := s.db.ExecContext(ctx, sqlDeleteFailedReactionByID, id)
+	if err != nil {
+		return false, fmt.Errorf("delete failed reaction: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// DeleteFailedReactionsOlderThanDate deletes failed reactions first recorded strictly before cutoffDate (date-only compare).
+func (s *SQLiteStore) DeleteFailedReactionsOlderThanDate(ctx context.Context, cutoffDate time.Time) (int64, error) {
+	defer metrics.ObserveStoreQuery("delete_failed_reactions_older_than_date", time.Now())
+	res, err := s.db.ExecContext(ctx, sqlDeleteFailedReactionsOlderThanDate, cutoffDate.UTC().Format("2006-01-02"))
+	if err != nil {
+		return 0, fmt.Errorf("delete failed reactions older than: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n, nil
+}
+
+// CountFailedReactionsOlderThanDate counts rows that DeleteFailedReactionsOlderThanDate would delete.
+func (s *SQLiteStore) CountFailedReactionsOlderThanDate(ctx context.Context, cutoffDate time.Time) (int64, error) {
+	defer metrics.ObserveStoreQuery("count_failed_reactions_older_than_date", time.Now())
+	var n int64
+	if err := s.db.QueryRowContext(ctx, sqlCountFailedReactionsOlderThanDate, cutoffDate.UTC().Format("2006-01-02")).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count failed reactions older than: %w", err)
+	}
+	return n, nil
+}
+
+// DeleteQueuedReactionsOlderThanDate deletes queued reactions created before cutoffDate, ones the
+// flush loop never got to, e.g. because queueing was turned off while they waited.
+func (s *SQLiteStore) DeleteQueuedReactionsOlderThanDate(ctx context.Context, cutoffDate time.Time) (int64, error) {
+	defer metrics.ObserveStoreQuery("delete_queued_reactions_older_than_date", time.Now())
+	res, err := s.db.ExecContext(ctx, sqlDeleteQueuedReactionsOlderThanDate, cutoffDate.UTC().Format("2006-01-02"))
+	if err != nil {
+		return 0, fmt.Errorf("delete queued reactions older than: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n, nil
+}
+
+// CountQueuedReactionsOlderThanDate counts rows that DeleteQueuedReactionsOlderThanDate would delete.
+func (s *SQLiteStore) CountQueuedReactionsOlderThanDate(ctx context.Context, cutoffDate time.Time) (int64, error) {
+	defer metrics.ObserveStoreQuery("count_queued_reactions_older_than_date", time.Now())
+	var n int64
+	if err := s.db.QueryRowContext(ctx, sqlCountQueuedReactionsOlderThanDate, cutoffDate.UTC().Format("2006-01-02")).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count queued reactions older than: %w", err)
+	}
+	return n, nil
+}
+
+// DeleteOrphanedActivity deletes activity rows of PRs that no longer have any messages.
+func (s *SQLiteStore) DeleteOrphanedActivity(ctx context.Context) (int64, error) {
+	defer metrics.ObserveStoreQuery("delete_orphaned_activity", time.Now())
+	res, err := s.db.ExecContext(ctx, sqlDeleteOrphanedActivity)
+	if err != nil {
+		return 0, fmt.Errorf("delete orphaned activity: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n, nil
+}
+
+// CountOrphanedActivity counts activity rows of PRs that no longer have any messages.
+func (s *SQLiteStore) CountOrphanedActivity(ctx context.Context) (int64, error) {
+	defer metrics.ObserveStoreQuery("count_orphaned_activity", time.Now())
+	var n int64
+	if err := s.db.QueryRowContext(ctx, sqlCountOrphanedActivity).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count orphaned activity: %w", err)
+	}
+	return n, nil
+}
+
+// DeleteOrphanedReactions deletes reaction rows of PRs that no longer have any messages.
+func (s *SQLiteStore) DeleteOrphanedReactions(ctx context.Context) (int64, error) {
+	defer metrics.ObserveStoreQuery("delete_orphaned_reactions", time.Now())
+	res, err := s.db.ExecContext(ctx, sqlDeleteOrphanedReactions)
+	if err != nil {
+		return 0, fmt.Errorf("delete orphaned reactions: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n, nil
+}
+
+// CountOrphanedReactions counts reaction rows of PRs that no longer have any messages.
+func (s *SQLiteStore) CountOrphanedReactions(ctx context.Context) (int64, error) {
+	defer metrics.ObserveStoreQuery("count_orphaned_reactions", time.Now())
+	var n int64
+	if err := s.db.QueryRowContext(ctx, sqlCountOrphanedReactions).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count orphaned reactions: %w", err)
+	}
+	return n, nil
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		tracing.Fail(span, err)
+	}
+	span.End()
+}
+
+// timestampLayouts are the formats SQLite and the sqlite3 driver write TIMESTAMP columns in.
+var timestampLayouts = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02T15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05Z",
+}
+
+func parseTimestamp(s string) time.Time {
+	for _, layout := range timestampLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.UTC); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// SaveSlackInstallation stores the bot token for a workspace, replacing one from an earlier install.
+func (s *SQLiteStore) SaveSlackInstallation(ctx context.Context, inst SlackInstallation) error {
+	defer metrics.ObserveStoreQuery("save_slack_installation", time.Now())
+	if _, err := s.db.ExecContext(ctx, sqlUpsertSlackInstallation, inst.TeamID, inst.TeamName, inst.BotUserID, inst.BotToken, inst.Scope); err != nil {
+		return fmt.Errorf("save slack installation: %w", err)
+	}
+	return nil
+}
+
+// GetSlackInstallation returns the installation for a workspace, or ErrNotFound.
+func (s *SQLiteStore) GetSlackInstallation(ctx context.Context, teamID string) (SlackInstallation, error) {
+	defer metrics.ObserveStoreQuery("get_slack_installation", time.Now())
+	inst, err := scanSlackInstallation(s.db.QueryRowContext(ctx, sqlSelectSlackInstallationByTeam, teamID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return SlackInstallation{}, ErrNotFound
+	}
+	if err != nil {
+		return SlackInstallation{}, fmt.Errorf("get slack installation: %w", err)
+	}
+	return inst, nil
+}
+
+// ListSlackInstallations returns every installed workspace, oldest first.
+func (s *SQLiteStore) ListSlackInstallations(ctx context.Context) ([]SlackInstallation, error) {
+	defer metrics.ObserveStoreQuery("list_slack_installations", time.Now())
+	rows, err := s.db.QueryContext(ctx, sqlSelectSlackInstallations)
+	if err != nil {
+		return nil, fmt.Errorf("list slack installations: %w", err)
+	}
+	defer rows.Close()
+
+	var out []SlackInstallation
+	for rows.Next() {
+		inst, err := scanSlackInstallation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan slack installation: %w", err)
+		}
+		out = append(out, inst)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+	return out, nil
+}
+
+// DeleteSlackInstallation forgets a workspace's token and reports whether it was installed.
+func (s *SQLiteStore) DeleteSlackInstallation(ctx context.Context, teamID string) (bool, error) {
+	defer metrics.ObserveStoreQuery("delete_slack_installation", time.Now())
+	res, err := s.db.ExecContext(ctx, sqlDeleteSlackInstallation, teamID)
+	if err != nil {
+		return false, fmt.Errorf("delete slack installation: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// DeleteTeam forgets everything prmoji tracks in the Slack workspace teamID: its messages and
+// its failed and queued reactions. It returns how many messages were deleted.
+func (s *SQLiteStore) DeleteTeam(ctx context.Context, teamID string) (n int64, err error) {
+	defer metrics.ObserveStoreQuery("delete_team", time.Now())
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, sqlDeleteMessagesByTeam, teamID)
+		if err != nil {
+			return fmt.Errorf("delete messages by team: %w", err)
+		}
+		n, _ = res.RowsAffected()
+		if _, err := tx.ExecContext(ctx, sqlDeleteFailedReactionsByTeam, teamID); err != nil {
+			return fmt.Errorf("delete failed reactions by team: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, sqlDeleteQueuedReactionsByTeam, teamID); err != nil {
+			return fmt.Errorf("delete queued reactions by team: %w", err)
+		}
+		return nil
+	})
+	return n, err
+}
+
+// AssignLegacyTeam gives rows stored before messages were scoped to a workspace the
+// workspace teamID, the one of SLACK_TOKEN. It returns how many messages it updated.
+func (s *SQLiteStore) AssignLegacyTeam(ctx context.Context, teamID string) (int64, error) {
+	defer metrics.ObserveStoreQuery("assign_legacy_team", time.Now())
+	res, err := s.db.ExecContext(ctx, sqlAssignLegacyTeamMessages, teamID)
+	if err != nil {
+		return 0, fmt.Errorf("assign legacy team to messages: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	if _, err := s.db.ExecContext(ctx, sqlAssignLegacyTeamFailedReactions, teamID); err != nil {
+		return 0, fmt.Errorf("assign legacy team to failed reactions: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, sqlAssignLegacyTeamQueuedReactions, teamID); err != nil {
+		return 0, fmt.Errorf("assign legacy team to queued reactions: %w", err)
+	}
+	return n, nil
+}
+
+func scanSlackInstallation(row interface{ Scan(...any) error }) (SlackInstallation, error) {
+	var inst SlackInstallation
+	err := row.Scan(&inst.TeamID, &inst.TeamName, &inst.BotUserID, &inst.BotToken, &inst.Scope, &inst.InstalledAt, &inst.UpdatedAt)
+	return inst, err
+}
+
+// QueueReaction holds a reaction back until qr.DueAt. Queuing the same message and emoji again
+// moves its due time instead of adding it twice.
+func (s *SQLiteStore) QueueReaction(ctx context.Context, qr QueuedReaction) error {
+	defer metrics.ObserveStoreQuery("queue_reaction", time.Now())
+	if _, err := s.db.ExecContext(ctx, sqlUpsertQueuedReaction, qr.PRURL, qr.TeamID, qr.Channel, qr.TS, qr.Emoji, qr.DueAt.Unix()); err != nil {
+		return fmt.Errorf("queue reaction: %w", err)
+	}
+	return nil
+}
+
+// ListDueReactions returns up to limit queued reactions due at or before now, earliest first.
+func (s *SQLiteStore) ListDueReactions(ctx context.Context, now time.Time, limit int) ([]QueuedReaction, error) {
+	defer metrics.ObserveStoreQuery("list_due_reactions", time.Now())
+	rows, err := s.db.QueryContext(ctx, sqlSelectDueReactions, now.Unix(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("list due reactions: %w", err)
+	}
+	defer rows.Close()
+
+	var out []QueuedReaction
+	for rows.Next() {
+		var qr QueuedReaction
+		var dueAt int64
+		if err := rows.Scan(&qr.ID, &qr.CreatedAt, &qr.PRURL, &qr.TeamID, &qr.Channel, &qr.TS, &qr.Emoji, &dueAt); err != nil {
+			return nil, fmt.Errorf("scan queued reaction: %w", err)
+		}
+		qr.DueAt = time.Unix(dueAt, 0).UTC()
+		out = append(out, qr)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+	return out, nil
+}
+
+// CountQueuedReactions counts the reactions waiting to be added.
+func (s *SQLiteStore) CountQueuedReactions(ctx context.Context) (int64, error) {
+	defer metrics.ObserveStoreQuery("count_queued_reactions", time.Now())
+	var n int64
+	if err := s.db.QueryRowContext(ctx, sqlCountQueuedReactions).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count queued reactions: %w", err)
+	}
+	return n, nil
+}
+
+// DeleteQueuedReaction removes a queued reaction and reports whether it existed.
+func (s *SQLiteStore) DeleteQueuedReaction(ctx context.Context, id int64) (bool, error) {
+	defer metrics.ObserveStoreQuery("delete_queued_reaction", time.Now())
+	res, err := s.db.ExecContext(ctx, sqlDeleteQueuedReactionByID, id)
+	if err != nil {
+		return false, fmt.Errorf("delete queued reaction: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// DeleteQueuedReactionsByPRURL drops the queued emoji reactions on every message of a PR,
+// returning how many there were.
+func (s *SQLiteStore) DeleteQueuedReactionsByPRURL(ctx context.Context, prURL, emoji string) (int64, error) {
+	defer metrics.ObserveStoreQuery("delete_queued_reactions_by_pr_url", time.Now())
+	res, err := s.db.ExecContext(ctx, sqlDeleteQueuedReactionsByPRURLAndEmoji, prURL, emoji)
+	if err != nil {
+		return 0, fmt.Errorf("delete queued reactions: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n, nil
+}
@@ -0,0 +1,372 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestStore(t *testing.T) *SQLiteStore {
+	t.Helper()
+	st, err := NewSQLiteStore(filepath.Join(t.TempDir(), "prmoji.db"))
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+	return st
+}
+
+func TestDeleteInactiveBeforeDate(t *testing.T) {
+	ctx := context.Background()
+	st := newTestStore(t)
+
+	const (
+		stale  = "https://github.com/o/r/pull/1"
+		active = "https://github.com/o/r/pull/2"
+	)
+	for _, u := range []string{stale, active} {
+		if err := st.InsertPRMessage(ctx, u, "T1", "C1", "1.0"); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+	if _, err := st.db.ExecContext(ctx, `UPDATE pr_messages SET inserted_at = '2020-01-01 00:00:00';`); err != nil {
+		t.Fatalf("backdate: %v", err)
+	}
+	if err := st.TouchPRActivity(ctx, active); err != nil {
+		t.Fatalf("touch: %v", err)
+	}
+	if err := st.TouchPRActivity(ctx, "https://github.com/o/r/pull/404"); err != nil {
+		t.Fatalf("touch untracked: %v", err)
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -30)
+
+	n, err := st.CountOlderThanDate(ctx, cutoff, ChannelScope{})
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 rows by insertion age got %d (err %v)", n, err)
+	}
+	n, err = st.CountInactiveBeforeDate(ctx, cutoff, ChannelScope{})
+	if err != nil || n != 1 {
+		t.Fatalf("expected 1 inactive row got %d (err %v)", n, err)
+	}
+
+	if _, err := st.DeleteInactiveBeforeDate(ctx, cutoff, ChannelScope{}); err != nil {
+		t.Fatalf("delete inactive: %v", err)
+	}
+	if msgs, _ := st.ListMessagesByPRURL(ctx, stale); len(msgs) != 0 {
+		t.Fatalf("expected stale PR to be deleted")
+	}
+	if msgs, _ := st.ListMessagesByPRURL(ctx, active); len(msgs) != 1 {
+		t.Fatalf("expected active PR to be kept")
+	}
+}
+
+func TestChannelScope(t *testing.T) {
+	ctx := context.Background()
+	st := newTestStore(t)
+
+	for _, ch := range []string{"C1", "C2", "C3"} {
+		if err := st.InsertPRMessage(ctx, "https://github.com/o/r/pull/1", "T1", ch, "1.0"); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+	cutoff := time.Now().AddDate(0, 0, 1)
+
+	n, err := st.CountOlderThanDate(ctx, cutoff, ChannelScope{Channel: "C2"})
+	if err != nil || n != 1 {
+		t.Fatalf("expected 1 row in C2 got %d (err %v)", n, err)
+	}
+	n, err = st.DeleteInactiveBeforeDate(ctx, cutoff, ChannelScope{Exclude: []string{"C1", "C2"}})
+	if err != nil || n != 1 {
+		t.Fatalf("expected 1 row outside C1/C2 got %d (err %v)", n, err)
+	}
+	msgs, _ := st.ListMessagesByPRURL(ctx, "https://github.com/o/r/pull/1")
+	if len(msgs) != 2 {
+		t.Fatalf("expected 2 remaining messages got %d", len(msgs))
+	}
+}
+
+func TestDeleteOrphanedActivity(t *testing.T) {
+	ctx := context.Background()
+	st := newTestStore(t)
+
+	const u = "https://github.com/o/r/pull/1"
+	if err := st.InsertPRMessage(ctx, u, "T1", "C1", "1.0"); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	if err := st.TouchPRActivity(ctx, u); err != nil {
+		t.Fatalf("touch: %v", err)
+	}
+	if _, err := st.DeleteOlderThanDate(ctx, time.Now().AddDate(0, 0, 1), ChannelScope{}); err != nil {
+		t.Fatalf("delete older: %v", err)
+	}
+
+	n, err := st.DeleteOrphanedActivity(ctx)
+	if err != nil {
+		t.Fatalf("delete orphaned: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 orphaned activity row got %d", n)
+	}
+}
+
+func TestRecordFailedReaction(t *testing.T) {
+	ctx := context.Background()
+	st := newTestStore(t)
+
+	fr := FailedReaction{PRURL: "https://github.com/o/r/pull/1", Channel: "C1", TS: "1.0", Emoji: "white_check_mark", Error: "ratelimited"}
+	for range 2 {
+		if err := st.RecordFailedReaction(ctx, fr); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+	}
+	fr.Emoji = "speech_balloon"
+	if err := st.RecordFailedReaction(ctx, fr); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+
+	all, err := st.ListFailedReactions(ctx, 10, 0)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(all) != 2 || all[0].Attempts != 2 || all[1].Attempts != 1 {
+		t.Fatalf("unexpected failed reactions: %+v", all)
+	}
+
+	retryable, err := st.ListRetryableReactions(ctx, 2, 10)
+	if err != nil {
+		t.Fatalf("list retryable: %v", err)
+	}
+	if len(retryable) != 1 || retryable[0].Emoji != "speech_balloon" {
+		t.Fatalf("expected only the reaction below the attempt limit, got %+v", retryable)
+	}
+}
+
+func TestTrackPRMessage(t *testing.T) {
+	ctx := context.Background()
+	st := newTestStore(t)
+
+	const u = "https://github.com/o/r/pull/1"
+	for i, want := range []bool{true, false} {
+		inserted, err := st.TrackPRMessage(ctx, u, "T1", "C1", "1.0")
+		if err != nil {
+			t.Fatalf("track: %v", err)
+		}
+		if inserted != want {
+			t.Fatalf("call %d: expected inserted=%v", i, want)
+		}
+	}
+	msgs, err := st.ListMessagesByPRURL(ctx, u)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(msgs) != 1 {
+		t.Fatalf("expected 1 message got %d", len(msgs))
+	}
+}
+
+func TestSlackInstallations(t *testing.T) {
+	ctx := context.Background()
+	st := newTestStore(t)
+
+	inst := SlackInstallation{TeamID: "T2", TeamName: "Beta", BotUserID: "U2", BotToken: "xoxb-old", Scope: "reactions:write"}
+	if err := st.SaveSlackInstallation(ctx, inst); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	inst.BotToken = "xoxb-new"
+	if err := st.SaveSlackInstallation(ctx, inst); err != nil {
+		t.Fatalf("save again: %v", err)
+	}
+	got, err := st.GetSlackInstallation(ctx, "T2")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got.BotToken != "xoxb-new" {
+		t.Fatalf("expected the reinstall to replace the token, got %q", got.BotToken)
+	}
+
+	if found, err := st.DeleteSlackInstallation(ctx, "T2"); err != nil || !found {
+		t.Fatalf("delete: %v %v", found, err)
+	}
+	if _, err := st.GetSlackInstallation(ctx, "T2"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound got %v", err)
+	}
+}
+
+func TestDeleteTeam(t *testing.T) {
+	ctx := context.Background()
+	st := newTestStore(t)
+
+	const u = "https://github.com/o/r/pull/1"
+	for _, team := range []string{"T1", "T2"} {
+		if err := st.InsertPRMessage(ctx, u, team, "C"+team, "1.0"); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+		if err := st.QueueReaction(ctx, QueuedReaction{PRURL: u, TeamID: team, Channel: "C" + team, TS: "1.0", Emoji: "eyes", DueAt: time.Now()}); err != nil {
+			t.Fatalf("queue: %v", err)
+		}
+		if err := st.RecordFailedReaction(ctx, FailedReaction{PRURL: u, TeamID: team, Channel: "C" + team, TS: "1.0", Emoji: "eyes", Error: "timeout"}); err != nil {
+			t.Fatalf("record failed: %v", err)
+		}
+	}
+
+	if n, err := st.DeleteTeam(ctx, "T2"); err != nil || n != 1 {
+		t.Fatalf("expected 1 message deleted, got %d (%v)", n, err)
+	}
+	msgs, err := st.ListMessagesByPRURL(ctx, u)
+	if err != nil || len(msgs) != 1 || msgs[0].TeamID != "T1" {
+		t.Fatalf("expected only T1's message left, got %+v (%v)", msgs, err)
+	}
+	if n, err := st.CountQueuedReactions(ctx); err != nil || n != 1 {
+		t.Fatalf("expected only T1's queued reaction left, got %d (%v)", n, err)
+	}
+	if n, err := st.CountFailedReactions(ctx); err != nil || n != 1 {
+		t.Fatalf("expected only T1's failed reaction left, got %d (%v)", n, err)
+	}
+}
+
+func TestUntrackDropsQueuedReactions(t *testing.T) {
+	const u1, u2 = "https://github.com/o/r/pull/1", "https://github.com/o/r/pull/2"
+	cases := []struct {
+		name    string
+		untrack func(context.Context, *SQLiteStore) error
+		left    int64
+	}{
+		{"pr", func(ctx context.Context, st *SQLiteStore) error { return st.DeleteByPRURL(ctx, u1) }, 1},
+		{"pr in team", func(ctx context.Context, st *SQLiteStore) error {
+			_, err := st.DeleteByPRURLInTeam(ctx, u1, "T1")
+			return err
+		}, 2},
+		{"slack thread", func(ctx context.Context, st *SQLiteStore) error {
+			_, err := st.DeleteSlackThread(ctx, "C1", "1.0")
+			return err
+		}, 2},
+		{"message", func(ctx context.Context, st *SQLiteStore) error {
+			msgs, err := st.ListMessagesByPRURL(ctx, u2)
+			if err != nil || len(msgs) != 1 {
+				return fmt.Errorf("list: %v %+v", err, msgs)
+			}
+			_, err = st.DeleteMessage(ctx, msgs[0].ID)
+			return err
+		}, 3},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			st := newTestStore(t)
+			msgs := []struct{ prURL, team, channel, ts, threadTS string }{
+				{u1, "T1", "C1", "1.0", ""},
+				{u1, "T1", "C1", "1.1", "1.0"},
+				{u1, "T2", "C2", "1.0", ""},
+				{u2, "T1", "C3", "2.0", ""},
+			}
+			for _, m := range msgs {
+				if err := st.InsertPRThreadReply(ctx, m.prURL, m.team, m.channel, m.ts, m.threadTS); err != nil {
+					t.Fatalf("insert: %v", err)
+				}
+				if err := st.QueueReaction(ctx, QueuedReaction{PRURL: m.prURL, TeamID: m.team, Channel: m.channel, TS: m.ts, Emoji: "eyes", DueAt: time.Now()}); err != nil {
+					t.Fatalf("queue: %v", err)
+				}
+			}
+
+			if err := tc.untrack(ctx, st); err != nil {
+				t.Fatalf("untrack: %v", err)
+			}
+			if n, err := st.CountQueuedReactions(ctx); err != nil || n != tc.left {
+				t.Fatalf("expected %d queued reactions left, got %d (%v)", tc.left, n, err)
+			}
+		})
+	}
+}
+
+func TestInitSchemaAddsColumns(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "prmoji.db")
+
+	// A database created before messages were scoped to a Slack workspace.
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, `CREATE TABLE pr_messages (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		inserted_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		pr_url TEXT NOT NULL,
+		message_channel TEXT,
+		message_timestamp TEXT
+	);
+	INSERT INTO pr_messages(pr_url, message_channel, message_timestamp) VALUES('https://github.com/o/r/pull/1', 'C1', '1.0');`); err != nil {
+		t.Fatalf("create old schema: %v", err)
+	}
+	_ = db.Close()
+
+	st, err := NewSQLiteStore(path)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+	msgs, err := st.ListMessagesByPRURL(ctx, "https://github.com/o/r/pull/1")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(msgs) != 1 || msgs[0].TeamID != "" {
+		t.Fatalf("expected the old message without a team, got %+v", msgs)
+	}
+
+	if n, err := st.DeleteByPRURLInTeam(ctx, "https://github.com/o/r/pull/1", "T1"); err != nil || n != 0 {
+		t.Fatalf("expected the old message to belong to no team yet, got %d (%v)", n, err)
+	}
+	if n, err := st.AssignLegacyTeam(ctx, "T1"); err != nil || n != 1 {
+		t.Fatalf("expected 1 message assigned to T1, got %d (%v)", n, err)
+	}
+	if n, err := st.AssignLegacyTeam(ctx, "T2"); err != nil || n != 0 {
+		t.Fatalf("expected the assignment to happen once, got %d (%v)", n, err)
+	}
+	if n, err := st.DeleteByPRURLInTeam(ctx, "https://github.com/o/r/pull/1", "T1"); err != nil || n != 1 {
+		t.Fatalf("expected T1 to untrack the old message, got %d (%v)", n, err)
+	}
+}
+
+func TestQueuedReactions(t *testing.T) {
+	ctx := context.Background()
+	st := newTestStore(t)
+
+	now := time.Date(2026, 3, 11, 12, 0, 0, 0, time.UTC)
+	prURL := "https://github.com/o/r/pull/1"
+	queue := []QueuedReaction{
+		{PRURL: prURL, TeamID: "T1", Channel: "C1", TS: "1.0", Emoji: "speech_balloon", DueAt: now.Add(time.Minute)},
+		{PRURL: prURL, TeamID: "T1", Channel: "C1", TS: "1.0", Emoji: "white_check_mark", DueAt: now.Add(-time.Second)},
+		{PRURL: prURL, TeamID: "T1", Channel: "C1", TS: "1.0", Emoji: "speech_balloon", DueAt: now},
+		{PRURL: prURL, TeamID: "T1", Channel: "C2", TS: "2.0", Emoji: "white_check_mark", DueAt: now.Add(time.Hour)},
+	}
+	for _, qr := range queue {
+		if err := st.QueueReaction(ctx, qr); err != nil {
+			t.Fatalf("queue: %v", err)
+		}
+	}
+	if n, err := st.CountQueuedReactions(ctx); err != nil || n != 3 {
+		t.Fatalf("expected the repeated reaction to be queued once, got %d (%v)", n, err)
+	}
+
+	due, err := st.ListDueReactions(ctx, now, 10)
+	if err != nil {
+		t.Fatalf("list due: %v", err)
+	}
+	if len(due) != 2 || due[0].Emoji != "white_check_mark" || due[1].Emoji != "speech_balloon" || !due[1].DueAt.Equal(now) {
+		t.Fatalf("unexpected due reactions: %+v", due)
+	}
+
+	if found, err := st.DeleteQueuedReaction(ctx, due[0].ID); err != nil || !found {
+		t.Fatalf("delete: %v %v", found, err)
+	}
+	if n, err := st.DeleteQueuedReactionsByPRURL(ctx, prURL, "white_check_mark"); err != nil || n != 1 {
+		t.Fatalf("expected to drop the remaining approval, got %d (%v)", n, err)
+	}
+	if n, _ := st.CountQueuedReactions(ctx); n != 1 {
+		t.Fatalf("expected 1 queued reaction left got %d", n)
+	}
+}
@@ -0,0 +1,167 @@
+package suppress
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/adamantal/prmoji/internal/github"
+)
+
+const (
+	// Bots matches any bot account, e.g. dependabot[bot].
+	Bots = "{bot}"
+	// Author matches the PR's author acting on their own PR.
+	Author = "{author}"
+)
+
+// Event is a GitHub action to check against the rules.
+type Event struct {
+	Action github.Action
+	// Actor is the login the action is attributed to; ActorIsBot tells whether it is a bot account.
+	Actor      string
+	ActorIsBot bool
+	// Author is the login of the PR's author.
+	Author string
+	// Repo is "owner/repo".
+	Repo string
+}
+
+// Rule suppresses reactions to actions taken by the accounts it matches.
+type Rule struct {
+	// Raw is the rule as written, for logs.
+	Raw string
+	// Who is a login glob, Bots or Author; unused when Regexp is set.
+	Who    string
+	Regexp *regexp.Regexp
+	// Actions the rule applies to; empty means every action.
+	Actions []github.Action
+	// Repos are owner/repo globs the rule applies to; empty means every repository.
+	Repos []string
+}
+
+// Rules suppress an action when any of them matches.
+type Rules []Rule
+
+// Parse reads comma-separated rules written as `WHO[:ACTION|ACTION][@REPO|REPO]`. WHO is a login
+// glob, a /regexp/ (without commas or slashes) matched against the whole login, Bots or Author. ACTIONS default to
+// commented, and `*` applies the rule to every action. E.g.
+// `{bot},renovate*:commented|approved@acme/*,/^svc-[0-9]+$/:*`.
+func Parse(s string) (Rules, error) {
+	var rules Rules
+	for _, raw := range strings.Split(s, ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		r, err := parseRule(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule %q: %w", raw, err)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+func parseRule(raw string) (Rule, error) {
+	r := Rule{Raw: raw}
+	rest := raw
+	if strings.HasPrefix(rest, "/") {
+		end := strings.Index(rest[1:], "/")
+		if end < 0 {
+			return Rule{}, errors.New("unterminated regexp")
+		}
+		re, err := regexp.Compile("(?i)^(?:" + rest[1:end+1] + ")$")
+		if err != nil {
+			return Rule{}, err
+		}
+		r.Regexp = re
+		rest = rest[end+2:]
+	} else {
+		end := strings.IndexAny(rest, ":@")
+		if end < 0 {
+			end = len(rest)
+		}
+		r.Who = strings.ToLower(strings.TrimSpace(rest[:end]))
+		rest = rest[end:]
+		if r.Who == "" {
+			return Rule{}, errors.New("no login pattern")
+		}
+		if r.Who != Bots && r.Who != Author {
+			if _, err := path.Match(r.Who, ""); err != nil {
+				return Rule{}, errors.New("bad login pattern")
+			}
+		}
+	}
+
+	rest, repos, hasRepos := strings.Cut(rest, "@")
+	if hasRepos {
+		for _, repo := range strings.Split(repos, "|") {
+			repo = strings.ToLower(strings.TrimSpace(repo))
+			if _, err := path.Match(repo, ""); err != nil || !strings.Contains(repo, "/") {
+				return Rule{}, fmt.Errorf("bad repository pattern %q (want owner/repo)", repo)
+			}
+			r.Repos = append(r.Repos, repo)
+		}
+	}
+
+	switch {
+	case rest == "":
+		r.Actions = []github.Action{github.ActionCommented}
+	case !strings.HasPrefix(rest, ":"):
+		return Rule{}, fmt.Errorf("unexpected %q", rest)
+	case strings.TrimSpace(rest[1:]) == "*":
+	default:
+		for _, a := range strings.Split(rest[1:], "|") {
+			action := github.Action(strings.ToLower(strings.TrimSpace(a)))
+			if !slices.Contains(github.Actions, action) {
+				return Rule{}, fmt.Errorf("unknown action %q", a)
+			}
+			r.Actions = append(r.Actions, action)
+		}
+	}
+	return r, nil
+}
+
+// Match returns the first rule suppressing ev.
+func (rs Rules) Match(ev Event) (Rule, bool) {
+	for _, r := range rs {
+		if r.matches(ev) {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
+
+func (r Rule) matches(ev Event) bool {
+	if len(r.Actions) > 0 && !slices.Contains(r.Actions, ev.Action) {
+		return false
+	}
+	if len(r.Repos) > 0 && !matchAny(r.Repos, strings.ToLower(ev.Repo)) {
+		return false
+	}
+	actor := strings.ToLower(ev.Actor)
+	switch {
+	case r.Regexp != nil:
+		return actor != "" && r.Regexp.MatchString(actor)
+	case r.Who == Bots:
+		return ev.ActorIsBot
+	case r.Who == Author:
+		return actor != "" && actor == strings.ToLower(ev.Author)
+	default:
+		ok, _ := path.Match(r.Who, actor)
+		return actor != "" && ok
+	}
+}
+
+func matchAny(patterns []string, repo string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, repo); ok {
+			return true
+		}
+	}
+	return false
+}
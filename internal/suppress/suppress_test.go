@@ -0,0 +1,66 @@
+package suppress
+
+import (
+	"testing"
+
+	"github.com/adamantal/prmoji/internal/github"
+)
+
+func TestParse(t *testing.T) {
+	rules, err := Parse(" Bob , {bot}:* , renovate*:commented|Approved@acme/*|Other/web ,, /^svc-[0-9]+$/@acme/api ")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(rules) != 4 {
+		t.Fatalf("expected 4 rules got %d", len(rules))
+	}
+	if rules[0].Who != "bob" || len(rules[0].Actions) != 1 || rules[0].Actions[0] != github.ActionCommented {
+		t.Fatalf("unexpected plain rule: %+v", rules[0])
+	}
+	if rules[1].Who != Bots || rules[1].Actions != nil {
+		t.Fatalf("unexpected bot rule: %+v", rules[1])
+	}
+	if len(rules[2].Actions) != 2 || rules[2].Actions[1] != github.ActionApproved || rules[2].Repos[1] != "other/web" {
+		t.Fatalf("unexpected scoped rule: %+v", rules[2])
+	}
+	if rules[3].Regexp == nil || rules[3].Repos[0] != "acme/api" {
+		t.Fatalf("unexpected regexp rule: %+v", rules[3])
+	}
+
+	for _, bad := range []string{"bob:reviewed", "bob@acme", "/svc-(/", "/svc-", ":commented", "[bob", "bob:commented@", "/svc/x"} {
+		if _, err := Parse(bad); err == nil {
+			t.Fatalf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	rules, err := Parse("bob,{bot},{author}:commented|approved,renovate*:*@acme/*,/svc-[0-9]+/:merged")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	tests := []struct {
+		name string
+		ev   Event
+		want bool
+	}{
+		{"ignored commenter", Event{Action: github.ActionCommented, Actor: "Bob", Repo: "o/r"}, true},
+		{"ignored commenter approving", Event{Action: github.ActionApproved, Actor: "bob", Repo: "o/r"}, false},
+		{"bot comment", Event{Action: github.ActionCommented, Actor: "ci", ActorIsBot: true, Repo: "o/r"}, true},
+		{"bot merge", Event{Action: github.ActionMerged, Actor: "ci", ActorIsBot: true, Repo: "o/r"}, false},
+		{"author comment", Event{Action: github.ActionCommented, Actor: "carol", Author: "Carol", Repo: "o/r"}, true},
+		{"other's comment", Event{Action: github.ActionCommented, Actor: "dave", Author: "carol", Repo: "o/r"}, false},
+		{"glob in scoped repo", Event{Action: github.ActionClosed, Actor: "renovate-bot", Repo: "Acme/web"}, true},
+		{"glob outside scoped repo", Event{Action: github.ActionClosed, Actor: "renovate-bot", Repo: "other/web"}, false},
+		{"regexp matches whole login", Event{Action: github.ActionMerged, Actor: "svc-42", Repo: "o/r"}, true},
+		{"regexp partial login", Event{Action: github.ActionMerged, Actor: "svc-42x", Repo: "o/r"}, false},
+		{"unknown actor", Event{Action: github.ActionCommented, Repo: "o/r"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, got := rules.Match(tt.ev); got != tt.want {
+				t.Fatalf("expected %v got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -18,3 +18,13 @@ func EmojiForAction(a github.Action) string {
 		return "speech_balloon"
 	}
 }
+
+// Emojis override the reaction added for some actions; other actions get EmojiForAction's.
+type Emojis map[github.Action]string
+
+func (e Emojis) For(a github.Action) string {
+	if emoji, ok := e[a]; ok {
+		return emoji
+	}
+	return EmojiForAction(a)
+}